@@ -0,0 +1,181 @@
+package errs
+
+import "time"
+
+// Builder builds an *Error one element at a time, as a more explicit
+// alternative to E, whose arguments are told apart by their types:
+//
+//	err := errs.Build().
+//		Op("user.Create").
+//		Kind(errs.Validation).
+//		Param("email").
+//		Msg("email is required").
+//		Err()
+//
+// Each method sets one element and returns the Builder, calling it
+// again replaces the element. The zero value is ready to use.
+type Builder struct {
+	path        PathName
+	user        UserName
+	op          Op
+	kind        Kind
+	code        Code
+	param       Parameter
+	paramValue  ParamValue
+	userMessage UserMessage
+	retryAfter  RetryAfter
+	status      Status
+	headers     Headers
+	meta        Meta
+	msg         string
+	cause       error
+}
+
+// Build returns a new Builder
+func Build() *Builder {
+	return &Builder{}
+}
+
+// Path sets the path name of the item being accessed
+func (b *Builder) Path(path PathName) *Builder {
+	b.path = path
+	return b
+}
+
+// User sets the name of the user attempting the operation
+func (b *Builder) User(user UserName) *Builder {
+	b.user = user
+	return b
+}
+
+// Op sets the operation being performed
+func (b *Builder) Op(op Op) *Builder {
+	b.op = op
+	return b
+}
+
+// Kind sets the class of error
+func (b *Builder) Kind(kind Kind) *Builder {
+	b.kind = kind
+	return b
+}
+
+// Code sets the Code of the error
+func (b *Builder) Code(code Code) *Builder {
+	b.code = code
+	return b
+}
+
+// Param sets the parameter related to the error
+func (b *Builder) Param(param Parameter) *Builder {
+	b.param = param
+	return b
+}
+
+// ParamValue sets the value of the parameter related to the error,
+// as it was received
+func (b *Builder) ParamValue(value ParamValue) *Builder {
+	b.paramValue = value
+	return b
+}
+
+// UserMessage sets the message sent to the client in place of the
+// error message
+func (b *Builder) UserMessage(msg UserMessage) *Builder {
+	b.userMessage = msg
+	return b
+}
+
+// RetryAfter sets how long the client should wait before retrying
+func (b *Builder) RetryAfter(d time.Duration) *Builder {
+	b.retryAfter = RetryAfter(d)
+	return b
+}
+
+// Status sets an explicit HTTP Status Code for the error
+func (b *Builder) Status(status Status) *Builder {
+	b.status = status
+	return b
+}
+
+// Headers sets the HTTP headers sent with the error response
+func (b *Builder) Headers(h Headers) *Builder {
+	b.headers = h
+	return b
+}
+
+// Meta sets the metadata logged with the error
+func (b *Builder) Meta(m Meta) *Builder {
+	b.meta = m
+	return b
+}
+
+// Msg sets the error message. If Cause is also set, the message is
+// prepended to the message of the cause, as for Wrapf.
+func (b *Builder) Msg(msg string) *Builder {
+	b.msg = msg
+	return b
+}
+
+// Cause sets the underlying error that triggered this one. As with
+// E, the Kind, Code and Param of an *Error cause are pulled up if
+// they are not set.
+func (b *Builder) Cause(err error) *Builder {
+	b.cause = err
+	return b
+}
+
+// Err returns the built *Error as an error, it is never nil
+func (b *Builder) Err() error {
+	var args []interface{}
+	if b.path != "" {
+		args = append(args, b.path)
+	}
+	if b.user != "" {
+		args = append(args, b.user)
+	}
+	if b.op != "" {
+		args = append(args, b.op)
+	}
+	if b.kind != Other {
+		args = append(args, b.kind)
+	}
+	if b.code != "" {
+		args = append(args, b.code)
+	}
+	if b.param != "" {
+		args = append(args, b.param)
+	}
+	if b.paramValue != "" {
+		args = append(args, b.paramValue)
+	}
+	if b.userMessage != "" {
+		args = append(args, b.userMessage)
+	}
+	if b.retryAfter != 0 {
+		args = append(args, b.retryAfter)
+	}
+	if b.status != 0 {
+		args = append(args, b.status)
+	}
+	if len(b.headers) > 0 {
+		args = append(args, b.headers)
+	}
+	if len(b.meta) > 0 {
+		args = append(args, b.meta)
+	}
+	if b.cause != nil {
+		args = append(args, b.cause)
+	} else if b.msg != "" {
+		args = append(args, b.msg)
+	}
+	if len(args) == 0 {
+		return &Error{stack: callers(3)}
+	}
+	e := E(args...).(*Error)
+	if b.cause != nil && b.msg != "" {
+		e.msg = b.msg
+	}
+	e.stack = callers(3)
+	return e
+}
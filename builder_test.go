@@ -0,0 +1,89 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Code("user_not_found"), "no such user")
+	tests := []struct {
+		name    string
+		got     error
+		want    *Error
+		wantMsg string
+	}{
+		{
+			"elements",
+			Build().Op("user.Create").Kind(Validation).Code("email_required").Param("email").Msg("email is required").Err(),
+			&Error{Op: "user.Create", Kind: Validation, Code: "email_required", Param: "email"},
+			"email is required",
+		},
+		{
+			"last call wins",
+			Build().Kind(NotExist).Kind(Exist).Msg("first").Msg("second").Err(),
+			&Error{Kind: Exist},
+			"second",
+		},
+		{
+			"cause",
+			Build().Op("user.Find").Cause(sql.ErrNoRows).Err(),
+			&Error{Op: "user.Find"},
+			sql.ErrNoRows.Error(),
+		},
+		{
+			"*Error cause is pulled up",
+			Build().Op("service.Find").Cause(inner).Err(),
+			&Error{Op: "service.Find", Kind: NotExist, Code: "user_not_found"},
+			"no such user",
+		},
+		{
+			"message and cause",
+			Build().Op("user.Find").Kind(Database).Cause(sql.ErrConnDone).Msg("find user").Err(),
+			&Error{Op: "user.Find", Kind: Database},
+			"find user: " + sql.ErrConnDone.Error(),
+		},
+		{
+			"user message and retry",
+			Build().Kind(TooManyRequests).UserMessage("slow down").RetryAfter(time.Second).Err(),
+			&Error{Kind: TooManyRequests, UserMessage: "slow down", RetryAfter: RetryAfter(time.Second)},
+			"",
+		},
+		{
+			"path, user, value, status, headers and meta",
+			Build().Path("a@b.com/dir").User("a@b.com").Param("limit").ParamValue("-5").Status(451).
+				Headers(Headers{"Link": "<https://example.com/legal>; rel=blocked-by"}).Meta(Meta{"region": "eu"}).Msg("blocked").Err(),
+			&Error{Path: "a@b.com/dir", User: "a@b.com", Param: "limit", ParamValue: "-5", Status: 451,
+				Headers: Headers{"Link": "<https://example.com/legal>; rel=blocked-by"}, Meta: Meta{"region": "eu"}},
+			"blocked",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := tt.got.(*Error)
+			if !ok {
+				t.Fatalf("Err() returned %T, want *Error", tt.got)
+			}
+			if e.Op != tt.want.Op || e.Kind != tt.want.Kind || e.Code != tt.want.Code || e.Param != tt.want.Param ||
+				e.UserMessage != tt.want.UserMessage || e.RetryAfter != tt.want.RetryAfter ||
+				e.Path != tt.want.Path || e.User != tt.want.User || e.ParamValue != tt.want.ParamValue || e.Status != tt.want.Status ||
+				!reflect.DeepEqual(e.Headers, tt.want.Headers) || !reflect.DeepEqual(e.Meta, tt.want.Meta) {
+				t.Errorf("Err() = %+v, want %+v", e, tt.want)
+			}
+			if got := e.Message(); got != tt.wantMsg {
+				t.Errorf("Err().Message() = %q, want %q", got, tt.wantMsg)
+			}
+			if frames := StackTrace(e); len(frames) == 0 || !strings.Contains(frames[0].Function, "TestBuilder") {
+				t.Errorf("StackTrace(Err()) = %v, want the caller of Err first", frames)
+			}
+		})
+	}
+
+	if err := Build().Cause(sql.ErrConnDone).Msg("find user").Err(); !errors.Is(err, sql.ErrConnDone) {
+		t.Error("errors.Is(Err(), cause) = false, want true")
+	}
+}
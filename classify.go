@@ -0,0 +1,113 @@
+package errs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// Matcher classifies an error, returning its Kind and whether it
+// recognized err. Matchers are typically written for the errors of a
+// specific driver, e.g. to match the unique violations of MySQL:
+//
+//	errs.RegisterMatcher(func(err error) (errs.Kind, bool) {
+//		var me *mysql.MySQLError
+//		if errors.As(err, &me) && me.Number == 1062 {
+//			return errs.Exist, true
+//		}
+//		return errs.Other, false
+//	})
+type Matcher func(err error) (Kind, bool)
+
+var (
+	matchersMu sync.RWMutex
+	// matchers are the Matchers added through RegisterMatcher,
+	// in registration order
+	matchers []Matcher
+)
+
+// RegisterMatcher adds m to the Matchers consulted by Classify.
+// Registered Matchers are consulted in registration order, before
+// the built-in ones, and the first to recognize an error decides its
+// Kind.
+//
+// RegisterMatcher is typically called during program initialization,
+// but it is safe for concurrent use.
+func RegisterMatcher(m Matcher) {
+	if m == nil {
+		return
+	}
+	matchersMu.Lock()
+	matchers = append(matchers, m)
+	matchersMu.Unlock()
+}
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation
+const uniqueViolation = "23505"
+
+// builtinMatchers are consulted by Classify after the Matchers
+// added through RegisterMatcher
+var builtinMatchers = []Matcher{
+	func(err error) (Kind, bool) {
+		return NotExist, errors.Is(err, sql.ErrNoRows)
+	},
+	func(err error) (Kind, bool) {
+		return Timeout, errors.Is(err, context.DeadlineExceeded)
+	},
+	func(err error) (Kind, bool) {
+		return Canceled, errors.Is(err, context.Canceled)
+	},
+	// drivers reporting a SQLSTATE, such as the PostgreSQL drivers
+	// lib/pq and pgx
+	func(err error) (Kind, bool) {
+		var se interface{ SQLState() string }
+		return Exist, errors.As(err, &se) && se.SQLState() == uniqueViolation
+	},
+}
+
+// Classify returns an *Error wrapping err with a Kind chosen by the
+// first Matcher to recognize err, see RegisterMatcher. Once the
+// registered Matchers are consulted, the built-in ones map:
+//   - sql.ErrNoRows to NotExist
+//   - context.DeadlineExceeded to Timeout
+//   - context.Canceled to Canceled
+//   - errors with a SQLState method returning 23505, the unique
+//     violations of PostgreSQL drivers, to Exist
+//
+// An error no Matcher recognizes is Unanticipated. If err already
+// is an *Error with a Kind, it is returned unchanged, and if it wraps
+// one, e.g. through fmt.Errorf and %w, its Kind is kept. Classify
+// returns nil if err is nil.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok && e.Kind != Other {
+		return e
+	}
+	kind := KindOf(err)
+	if kind == Other {
+		kind = classify(err)
+	}
+	e := E(kind, err).(*Error)
+	e.stack = callers(3)
+	return e
+}
+
+// classify returns the Kind of err chosen by the Matchers
+func classify(err error) Kind {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	for _, m := range matchers {
+		if k, ok := m(err); ok {
+			return k
+		}
+	}
+	for _, m := range builtinMatchers {
+		if k, ok := m(err); ok {
+			return k
+		}
+	}
+	return Unanticipated
+}
@@ -0,0 +1,81 @@
+package errs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// pqError mimics the errors of PostgreSQL drivers
+type pqError struct{ code string }
+
+func (e *pqError) Error() string    { return "pq: error " + e.code }
+func (e *pqError) SQLState() string { return e.code }
+
+// mysqlError mimics the errors of a driver without a SQLState method
+type mysqlError struct{ number int }
+
+func (e *mysqlError) Error() string { return fmt.Sprintf("Error %d", e.number) }
+
+func TestClassify(t *testing.T) {
+	RegisterMatcher(func(err error) (Kind, bool) {
+		var me *mysqlError
+		if errors.As(err, &me) && me.number == 1062 {
+			return Exist, true
+		}
+		return Other, false
+	})
+	defer func() {
+		matchersMu.Lock()
+		matchers = nil
+		matchersMu.Unlock()
+	}()
+
+	notExist := E(NotExist, "no such user")
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"no rows", sql.ErrNoRows, NotExist},
+		{"wrapped no rows", fmt.Errorf("find user: %w", sql.ErrNoRows), NotExist},
+		{"deadline", context.DeadlineExceeded, Timeout},
+		{"canceled", context.Canceled, Canceled},
+		{"unique violation", &pqError{code: "23505"}, Exist},
+		{"other SQLSTATE", &pqError{code: "42601"}, Unanticipated},
+		{"registered matcher", &mysqlError{number: 1062}, Exist},
+		{"unknown", errors.New("boom"), Unanticipated},
+		{"*Error", notExist, NotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Classify(tt.err)
+			if e.Kind != tt.want {
+				t.Errorf("Classify(%v).Kind = %v, want %v", tt.err, e.Kind, tt.want)
+			}
+			if !errors.Is(e, tt.err) {
+				t.Errorf("errors.Is(Classify(%v), err) = false, want true", tt.err)
+			}
+		})
+	}
+
+	if Classify(notExist) != notExist {
+		t.Error("Classify() changed an *Error with a Kind")
+	}
+	if Classify(nil) != nil {
+		t.Error("Classify(nil) != nil")
+	}
+}
+
+func TestHTTPErrorResponse_Canceled(t *testing.T) {
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), Classify(context.Canceled))
+	if w.Code != statusClientClosedRequest {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, statusClientClosedRequest)
+	}
+}
@@ -0,0 +1,51 @@
+package errs
+
+import "sync"
+
+// registeredCode describes a Code registered through RegisterCode
+type registeredCode struct {
+	httpStatus int
+	message    string
+}
+
+var (
+	codesMu sync.RWMutex
+	// registeredCodes holds the Codes registered through RegisterCode
+	registeredCodes = make(map[Code]registeredCode)
+)
+
+// RegisterCode adds code to the catalog of known error Codes, with
+// the HTTP Status Code HTTPErrorResponse sends for it and a default
+// message. Registering a Code again replaces the earlier entry.
+//
+// When E is called with a registered Code but no error or message,
+// the registered message is used as the error message. An explicit
+// string or error argument to E always takes precedence over the
+// registered message.
+//
+// The registered HTTP Status Code takes precedence over the one
+// chosen from the error Kind; pass 0 to keep the status code of the
+// Kind. An empty message adds no default message.
+//
+// RegisterCode panics if code is empty. It is typically called during
+// program initialization, but it is safe for concurrent use.
+func RegisterCode(code Code, httpStatus int, message string) {
+	if code == "" {
+		panic("errs.RegisterCode: empty code")
+	}
+	codesMu.Lock()
+	registeredCodes[code] = registeredCode{httpStatus: httpStatus, message: message}
+	codesMu.Unlock()
+}
+
+// lookupCode returns the description of a Code registered through
+// RegisterCode
+func lookupCode(code Code) (registeredCode, bool) {
+	if code == "" {
+		return registeredCode{}, false
+	}
+	codesMu.RLock()
+	rc, ok := registeredCodes[code]
+	codesMu.RUnlock()
+	return rc, ok
+}
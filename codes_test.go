@@ -0,0 +1,71 @@
+package errs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRegisterCode(t *testing.T) {
+	RegisterCode("plan_limit", http.StatusPaymentRequired, "plan limit reached")
+	RegisterCode("no_status", 0, "no status registered")
+	defer func() {
+		codesMu.Lock()
+		delete(registeredCodes, "plan_limit")
+		delete(registeredCodes, "no_status")
+		codesMu.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			"registered message",
+			E(Validation, Code("plan_limit")),
+			http.StatusPaymentRequired,
+			`{"error":{"kind":"input_validation_error","code":"plan_limit","message":"plan limit reached"}}`,
+		},
+		{
+			"explicit message",
+			E(Validation, Code("plan_limit"), "only 3 projects allowed"),
+			http.StatusPaymentRequired,
+			`{"error":{"kind":"input_validation_error","code":"plan_limit","message":"only 3 projects allowed"}}`,
+		},
+		{
+			"no registered status",
+			E(NotExist, Code("no_status")),
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","code":"no_status","message":"no status registered"}}`,
+		},
+		{
+			"unregistered code",
+			E(NotExist, Code("other_code"), "no such user"),
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","code":"other_code","message":"no such user"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != tt.wantCode {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error(`RegisterCode("") did not panic`)
+		}
+	}()
+	RegisterCode("", http.StatusTeapot, "")
+}
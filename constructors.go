@@ -0,0 +1,53 @@
+package errs
+
+import "errors"
+
+// NotFound returns an error for a missing entity, i.e. an *Error with
+// Kind NotExist, Param param and message as the error message, which
+// HTTPErrorResponse sends as 404 Not Found.
+func NotFound(param Parameter, message string) *Error {
+	return newKind(NotExist, param, errors.New(message))
+}
+
+// NotFoundf is like NotFound, but formats the error message
+// according to a format specifier, see Errorf.
+func NotFoundf(param Parameter, format string, args ...interface{}) *Error {
+	return newKind(NotExist, param, errorf(format, args...))
+}
+
+// Unauthenticatedf returns an *Error with Kind Unauthenticated whose
+// error message is formatted according to a format specifier, see
+// Errorf.
+func Unauthenticatedf(format string, args ...interface{}) *Error {
+	return newKind(Unauthenticated, "", errorf(format, args...))
+}
+
+// Unauthorizedf returns an *Error with Kind Unauthorized whose error
+// message is formatted according to a format specifier, see Errorf.
+func Unauthorizedf(format string, args ...interface{}) *Error {
+	return newKind(Unauthorized, "", errorf(format, args...))
+}
+
+// Invalidf returns an *Error with Kind Invalid whose error message
+// is formatted according to a format specifier, see Errorf.
+func Invalidf(format string, args ...interface{}) *Error {
+	return newKind(Invalid, "", errorf(format, args...))
+}
+
+// Internalf returns an *Error with Kind Internal whose error message
+// is formatted according to a format specifier, see Errorf.
+func Internalf(format string, args ...interface{}) *Error {
+	return newKind(Internal, "", errorf(format, args...))
+}
+
+// Databasef returns an *Error with Kind Database whose error message
+// is formatted according to a format specifier, see Errorf.
+func Databasef(format string, args ...interface{}) *Error {
+	return newKind(Database, "", errorf(format, args...))
+}
+
+// newKind builds the error for the exported constructors, recording
+// the stack of their caller
+func newKind(kind Kind, param Parameter, err error) *Error {
+	return &Error{Kind: kind, Param: param, Err: err, stack: callers(4)}
+}
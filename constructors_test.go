@@ -0,0 +1,48 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConstructors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *Error
+		wantKind   Kind
+		wantParam  Parameter
+		wantMsg    string
+		wantStatus int
+	}{
+		{"NotFound", NotFound("user_id", "no such user"), NotExist, "user_id", "no such user", http.StatusNotFound},
+		{"NotFoundf", NotFoundf("user_id", "no user %d", 42), NotExist, "user_id", "no user 42", http.StatusNotFound},
+		{"Unauthenticatedf", Unauthenticatedf("token for %s expired", "gilcrest"), Unauthenticated, "", "token for gilcrest expired", http.StatusUnauthorized},
+		{"Unauthorizedf", Unauthorizedf("%s cannot delete orders", "gilcrest"), Unauthorized, "", "gilcrest cannot delete orders", http.StatusForbidden},
+		{"Invalidf", Invalidf("page size %d is too large", 1000), Invalid, "", "page size 1000 is too large", http.StatusBadRequest},
+		{"Internalf", Internalf("render %s", "invoice"), Internal, "", "render invoice", http.StatusInternalServerError},
+		{"Databasef", Databasef("find user: %w", sql.ErrConnDone), Database, "", "find user: sql: connection is already closed", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Kind != tt.wantKind || tt.err.Param != tt.wantParam {
+				t.Errorf("Kind, Param = %v, %q, want %v, %q", tt.err.Kind, tt.err.Param, tt.wantKind, tt.wantParam)
+			}
+			if got := tt.err.Message(); got != tt.wantMsg {
+				t.Errorf("Message() = %q, want %q", got, tt.wantMsg)
+			}
+			if got := tt.err.StatusCode(); got != tt.wantStatus {
+				t.Errorf("StatusCode() = %d, want %d", got, tt.wantStatus)
+			}
+			if frames := StackTrace(tt.err); len(frames) == 0 || !strings.Contains(frames[0].Function, "TestConstructors") {
+				t.Errorf("StackTrace() = %v, want the caller of %s first", frames, tt.name)
+			}
+		})
+	}
+
+	if err := Databasef("find user: %w", sql.ErrConnDone); !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("errors.Is(Databasef(%%w), sql.ErrConnDone) = false, want true")
+	}
+}
@@ -0,0 +1,112 @@
+package errs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// ContextExtractor returns structured log fields taken from a
+// context.Context, such as the trace or user IDs stashed there by
+// middleware. It returns nil if ctx holds none of its values.
+type ContextExtractor func(ctx context.Context) map[string]interface{}
+
+var (
+	extractorsMu sync.RWMutex
+	// extractors are the ContextExtractors added through
+	// RegisterContextExtractor, in registration order
+	extractors []ContextExtractor
+)
+
+// RegisterContextExtractor adds fn to the ContextExtractors consulted
+// by HTTPErrorResponseCtx. The fields of every extractor are added to
+// the logged error; when several return the same key, the extractor
+// registered last wins. For example, to log OpenTelemetry trace IDs:
+//
+//	errs.RegisterContextExtractor(func(ctx context.Context) map[string]interface{} {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return nil
+//		}
+//		return map[string]interface{}{"TraceID": sc.TraceID().String()}
+//	})
+//
+// RegisterContextExtractor is typically called during program
+// initialization, but it is safe for concurrent use.
+func RegisterContextExtractor(fn ContextExtractor) {
+	if fn == nil {
+		return
+	}
+	extractorsMu.Lock()
+	extractors = append(extractors, fn)
+	extractorsMu.Unlock()
+}
+
+// contextFields returns the log fields of all registered
+// ContextExtractors for ctx
+func contextFields(ctx context.Context) map[string]interface{} {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	var fields map[string]interface{}
+	for _, extract := range extractors {
+		for k, v := range extract(ctx) {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// correlationIDKey is the context key for a correlation ID
+type correlationIDKey struct{}
+
+// NewContextWithCorrelationID returns a copy of ctx carrying the
+// correlation ID id, which HTTPErrorResponseCtx sends to the client
+// and logs as HTTPErrorResponseWithID does.
+func NewContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID stored in ctx
+// by NewContextWithCorrelationID, if any.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok
+}
+
+// HTTPErrorResponseCtx behaves like HTTPErrorResponse, but adds
+// request-scoped values from ctx to the logged error. The values
+// read from ctx are:
+//   - the correlation ID set by NewContextWithCorrelationID, which
+//     is logged as CorrelationID and sent to the client
+//   - the fields returned by every ContextExtractor added through
+//     RegisterContextExtractor, which are only logged
+//   - the language set by NewContextWithLanguage, which messages
+//     are translated to as HTTPErrorResponseLang does
+func HTTPErrorResponseCtx(ctx context.Context, w http.ResponseWriter, logger zerolog.Logger, err error) {
+	_, _ = WriteErrorCtx(ctx, w, zerologLogger(logger), err)
+}
+
+// WriteErrorCtx sends err as a response to the client exactly as
+// HTTPErrorResponseCtx does, but logs through lgr, as WriteError
+// does, and returns the HTTP Status Code that was sent and any error
+// from writing the response body to w. If lgr is nil, nothing is
+// logged.
+func WriteErrorCtx(ctx context.Context, w http.ResponseWriter, lgr Logger, err error) (int, error) {
+	res, werr := ctxResponse(ctx, w, lgr).write(err)
+	return res.StatusCode, werr
+}
+
+// ctxResponse returns the response sending an error to w with the
+// request-scoped values of ctx, see HTTPErrorResponseCtx
+func ctxResponse(ctx context.Context, w http.ResponseWriter, lgr Logger) response {
+	r := response{w: w, lgr: lgr, encode: errorEncoder()}
+	r.correlationID, _ = CorrelationIDFromContext(ctx)
+	r.fields = contextFields(ctx)
+	r.lang, _ = LanguageFromContext(ctx)
+	return r
+}
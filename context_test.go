@@ -0,0 +1,64 @@
+package errs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+type userIDKey struct{}
+
+func TestHTTPErrorResponseCtx(t *testing.T) {
+	RegisterContextExtractor(func(ctx context.Context) map[string]interface{} {
+		id, ok := ctx.Value(userIDKey{}).(string)
+		if !ok {
+			return nil
+		}
+		return map[string]interface{}{"UserID": id}
+	})
+	defer func() {
+		extractorsMu.Lock()
+		extractors = nil
+		extractorsMu.Unlock()
+	}()
+
+	ctx := context.WithValue(context.Background(), userIDKey{}, "user-42")
+	ctx = NewContextWithCorrelationID(ctx, "req-123")
+
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	HTTPErrorResponseCtx(ctx, w, zerolog.New(buf), E(NotExist, "no such user"))
+
+	for _, want := range []string{`"UserID":"user-42"`, `"CorrelationID":"req-123"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTTPErrorResponseCtx() log = %s, want %s", buf, want)
+		}
+	}
+	if !strings.Contains(w.Body.String(), `"correlation_id":"req-123"`) {
+		t.Errorf("HTTPErrorResponseCtx() body = %s, want correlation_id", w.Body)
+	}
+	if strings.Contains(w.Body.String(), "user-42") {
+		t.Errorf("HTTPErrorResponseCtx() body = %s, extracted fields must only be logged", w.Body)
+	}
+
+	// a context without values logs no extra fields
+	buf.Reset()
+	HTTPErrorResponseCtx(context.Background(), httptest.NewRecorder(), zerolog.New(buf), E(NotExist, "no such user"))
+	if strings.Contains(buf.String(), "UserID") || strings.Contains(buf.String(), "CorrelationID") {
+		t.Errorf("HTTPErrorResponseCtx() log = %s, want no context fields", buf)
+	}
+
+	lgr := &recordingLogger{}
+	code, err := WriteErrorCtx(ctx, httptest.NewRecorder(), lgr, E(NotExist, "no such user"))
+	if code != http.StatusNotFound || err != nil {
+		t.Errorf("WriteErrorCtx() = %d, %v, want %d, nil", code, err, http.StatusNotFound)
+	}
+	if lgr.fields["UserID"] != "user-42" || lgr.fields["CorrelationID"] != "req-123" {
+		t.Errorf("WriteErrorCtx() logged fields = %v, want the context fields", lgr.fields)
+	}
+}
@@ -7,9 +7,15 @@ package errs
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 // Error is the type that implements the error interface.
@@ -29,25 +35,93 @@ type Error struct {
 	// Param is for when the error is parameter-specific and represents the parameter
 	// related to the error.
 	Param Parameter
+	// ParamValue is the rejected value of Param, if it is safe
+	// to send back to the client
+	ParamValue ParamValue
 	// Code is a human-readable, short representation of the error
 	Code Code
+	// RetryAfter is how long the client should wait before retrying,
+	// sent as the Retry-After header by HTTPErrorResponse
+	RetryAfter RetryAfter
+	// Status, if not zero, is the HTTP Status Code sent by
+	// HTTPErrorResponse in place of the one for the Kind
+	Status Status
+	// UserMessage, if set, is the message sent to the client in
+	// place of the error message, which is then only logged
+	UserMessage UserMessage
+	// Headers are HTTP headers set on the response by
+	// HTTPErrorResponse, such as WWW-Authenticate or Allow
+	Headers Headers
+	// Meta holds key/value pairs, such as a tenant or entity ID,
+	// logged with the error but never sent to the client
+	Meta Meta
 	// StripError denotes whether to remove the error "stack"
 	// If true, the error "stack" details are removed, if false,
 	// the error "stack" details are appended
 	StripError bool
 	// The underlying error that triggered this one, if any.
 	Err error
+
+	// msg is the message added by Wrapf, or the Msg of a Builder
+	// with a Cause, in front of the message of Err.
+	msg string
+
+	// stack holds the program counters of the stack where the
+	// error was created, see StackTrace.
+	stack []uintptr
 }
 
-func (e *Error) isZero() bool {
-	return e.Path == "" && e.User == "" && e.Op == "" && e.Kind == 0 && e.Err == nil
+// IsZero reports whether e carries no information worth reporting,
+// i.e. whether e is nil or has no Path, User, Op, Kind and Err. The
+// other fields, such as Code, Param or UserMessage, are not
+// considered. HTTPErrorResponse sends no body for such an error,
+// only the HTTP Status Code, and Error omits it from the string of
+// an error wrapping it.
+func (e *Error) IsZero() bool {
+	return e == nil || (e.Path == "" && e.User == "" && e.Op == "" && e.Kind == 0 && e.Err == nil)
 }
 
-// Unwrap method allows for unwrapping errors using errors.As
-func (e Error) Unwrap() error {
+// Unwrap returns the underlying error, if any, which allows
+// errors.Is and errors.As to traverse the chain of wrapped errors
+func (e *Error) Unwrap() error {
 	return e.Err
 }
 
+// Is reports whether e matches target, which allows errors.Is to
+// compare errors with sentinel values such as ErrNotFound. target
+// matches if it is an *Error without an Err, i.e. a sentinel, whose
+// Kind, unless it is Other, and Code, unless it is empty, equal
+// those of e. The other elements of target are ignored and a target
+// with neither a Kind nor a Code matches nothing. As errors.Is walks
+// the chain of wrapped errors, the Kind and Code can come from any
+// *Error in the chain, but both must come from the same one.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || t.Err != nil || (t.Kind == Other && t.Code == "") {
+		return false
+	}
+	return (t.Kind == Other || t.Kind == e.Kind) && (t.Code == "" || t.Code == e.Code)
+}
+
+// Sentinel errors for the common Kinds, to compare errors with
+// errors.Is, e.g. errors.Is(err, errs.ErrNotFound) reports whether
+// err is or wraps an *Error with Kind NotExist, see (*Error).Is.
+// Sentinels for a Code are defined the same way:
+//
+//	var ErrCardDeclined = &errs.Error{Code: "card_declined"}
+//
+// They are meant to be compared with, return an error built with E
+// or a constructor such as NotFound instead.
+var (
+	ErrNotFound        = &Error{Kind: NotExist}
+	ErrAlreadyExists   = &Error{Kind: Exist}
+	ErrValidation      = &Error{Kind: Validation}
+	ErrUnauthenticated = &Error{Kind: Unauthenticated}
+	ErrUnauthorized    = &Error{Kind: Unauthorized}
+	ErrInternal        = &Error{Kind: Internal}
+	ErrTimeout         = &Error{Kind: Timeout}
+)
+
 // UserName is a string representing a user
 type UserName string
 
@@ -76,9 +150,33 @@ type Kind uint8
 // the parameter related to the error.
 type Parameter string
 
+// ParamValue is the value of the parameter related to the error,
+// as it was received. It is sent to the client, so clients can
+// highlight what was wrong, and the redactor set through SetRedactor
+// is applied to it. Do not set it for secrets or personal data.
+type ParamValue string
+
 // Code is a human-readable, short representation of the error
 type Code string
 
+// RetryAfter is how long a client should wait before retrying
+// the request that failed
+type RetryAfter time.Duration
+
+// Status is an explicit HTTP Status Code for an error, for the rare
+// codes, such as 451 from a proxied upstream, which no Kind maps to
+type Status int
+
+// UserMessage is a safe, user-friendly message sent to the client
+// instead of the internal error message
+type UserMessage string
+
+// Headers are HTTP headers, by name, sent with the error response
+type Headers map[string]string
+
+// Meta is metadata logged with the error as structured fields
+type Meta map[string]interface{}
+
 // Kinds of errors.
 //
 // The values of the error kinds are common between both
@@ -86,59 +184,264 @@ type Code string
 // any items since that will change their values.
 // New items must be added only to the end.
 const (
-	Other           Kind = iota // Unclassified error. This value is not printed in the error message.
-	Invalid                     // Invalid operation for this type of item.
-	Permission                  // Permission denied.
-	IO                          // External I/O error such as network failure.
-	Exist                       // Item already exists.
-	NotExist                    // Item does not exist.
-	Private                     // Information withheld.
-	Internal                    // Internal error or inconsistency.
-	BrokenLink                  // Link target does not exist.
-	Database                    // Error from database.
-	Validation                  // Input validation error.
-	Unanticipated               // Unanticipated error.
-	InvalidRequest              // Invalid Request
-	Unauthenticated             // User did not properly authenticate
-	Unauthorized                // User is not authorized for the resource
+	Other            Kind = iota // Unclassified error. This value is not printed in the error message.
+	Invalid                      // Invalid operation for this type of item.
+	Permission                   // Permission denied.
+	IO                           // External I/O error such as network failure.
+	Exist                        // Item already exists.
+	NotExist                     // Item does not exist.
+	Private                      // Information withheld.
+	Internal                     // Internal error or inconsistency.
+	BrokenLink                   // Link target does not exist.
+	Database                     // Error from database.
+	Validation                   // Input validation error.
+	Unanticipated                // Unanticipated error.
+	InvalidRequest               // Invalid Request
+	Unauthenticated              // User did not properly authenticate
+	Unauthorized                 // User is not authorized for the resource
+	TooManyRequests              // Too many requests, the client is being rate limited
+	Timeout                      // Operation timed out
+	MethodNotAllowed             // Request method is not supported by the resource
+	Canceled                     // Operation canceled, usually by the client
+	Unavailable                  // Service temporarily unavailable, e.g. during shutdown
+	Conflict                     // Conflict with the current state of the item, e.g. a stale write
+
+	// lastKind is not a Kind, it marks the end of the built-in
+	// Kinds and must remain the last item of this list.
+	lastKind
 )
 
+// Strings of the built-in Kinds, returned by their String method and
+// sent as the kind of the error response. They are part of the wire
+// contract with clients and do not change, so client code and
+// contract tests can compare against them instead of hardcoding the
+// strings.
+const (
+	KindOtherString            = "other_error"
+	KindInvalidString          = "invalid_operation"
+	KindPermissionString       = "permission_denied"
+	KindIOString               = "I/O_error"
+	KindExistString            = "item_already_exists"
+	KindNotExistString         = "item_does_not_exist"
+	KindPrivateString          = "information_withheld"
+	KindInternalString         = "internal_error"
+	KindBrokenLinkString       = "link_target_does_not_exist"
+	KindDatabaseString         = "database_error"
+	KindValidationString       = "input_validation_error"
+	KindUnanticipatedString    = "unanticipated_error"
+	KindInvalidRequestString   = "invalid_request_error"
+	KindUnauthenticatedString  = "unauthenticated"
+	KindUnauthorizedString     = "unauthorized"
+	KindTooManyRequestsString  = "too_many_requests"
+	KindTimeoutString          = "timeout"
+	KindMethodNotAllowedString = "method_not_allowed"
+	KindCanceledString         = "canceled"
+	KindUnavailableString      = "unavailable"
+	KindConflictString         = "conflict"
+)
+
+// String returns the string of k, which HTTPErrorResponse sends as
+// the kind of the error response. The strings of the built-in Kinds
+// are snake_case, e.g. "item_does_not_exist" for NotExist, except
+// for the historical "I/O_error" of IO, see the KindNotExistString
+// constant and the others, and SnakeString. Registered Kinds return
+// the name given to RegisterKind.
 func (k Kind) String() string {
 	switch k {
 	case Other:
-		return "other_error"
+		return KindOtherString
 	case Invalid:
-		return "invalid_operation"
+		return KindInvalidString
 	case Permission:
-		return "permission_denied"
+		return KindPermissionString
 	case IO:
-		return "I/O_error"
+		return KindIOString
 	case Exist:
-		return "item_already_exists"
+		return KindExistString
 	case NotExist:
-		return "item_does_not_exist"
+		return KindNotExistString
 	case BrokenLink:
-		return "link_target_does_not_exist"
+		return KindBrokenLinkString
 	case Private:
-		return "information_withheld"
+		return KindPrivateString
 	case Internal:
-		return "internal_error"
+		return KindInternalString
 	case Database:
-		return "database_error"
+		return KindDatabaseString
 	case Validation:
-		return "input_validation_error"
+		return KindValidationString
 	case Unanticipated:
-		return "unanticipated_error"
+		return KindUnanticipatedString
 	case InvalidRequest:
-		return "invalid_request_error"
+		return KindInvalidRequestString
 	case Unauthenticated:
-		return "unauthenticated"
+		return KindUnauthenticatedString
 	case Unauthorized:
-		return "unauthorized"
+		return KindUnauthorizedString
+	case TooManyRequests:
+		return KindTooManyRequestsString
+	case Timeout:
+		return KindTimeoutString
+	case MethodNotAllowed:
+		return KindMethodNotAllowedString
+	case Canceled:
+		return KindCanceledString
+	case Unavailable:
+		return KindUnavailableString
+	case Conflict:
+		return KindConflictString
+	}
+	if ck, ok := lookupKind(k); ok {
+		return ck.name
 	}
 	return "unknown_error_kind"
 }
 
+// SnakeString returns the string of k in snake_case, for clients
+// whose style guide requires it everywhere: "io_error" for IO, whose
+// String is the historical "I/O_error", and the name given to
+// RegisterKind converted to snake_case, e.g. "rate_limited" for
+// "RateLimited" or "Rate Limited". The strings of the other built-in
+// Kinds are already snake_case and returned unchanged. To send it as
+// the kind of every error response, see SetSnakeCaseKinds.
+func (k Kind) SnakeString() string {
+	if k == IO {
+		return "io_error"
+	}
+	return snakeCase(k.String())
+}
+
+// snakeCase converts s to snake_case: upper case letters are lowered,
+// with an underscore before those starting a word in camelCase, and
+// every run of other characters than letters and digits becomes a
+// single underscore
+func snakeCase(s string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			if b.Len() > 0 && prev != '_' {
+				b.WriteByte('_')
+			}
+			r = '_'
+		}
+		prev = r
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// customKind describes a Kind registered through RegisterKind
+type customKind struct {
+	name       string
+	httpStatus int
+}
+
+var (
+	kindsMu sync.RWMutex
+	// customKinds holds the Kinds registered through RegisterKind
+	customKinds = make(map[Kind]customKind)
+	// nextKind is the value of the next registered Kind
+	nextKind = Kind(math.MaxUint8)
+)
+
+// RegisterKind registers a new Kind for a domain specific class of
+// error, such as "rate_limited", with the given name, returned by its
+// String method, and the HTTP Status Code HTTPErrorResponse sends for
+// it. SetStatusCodeMap, and SetCodeMap of package errsgrpc, can
+// change the HTTP and gRPC codes of a registered Kind as for any
+// other Kind.
+//
+// Registered Kinds are allocated downwards from the largest Kind
+// value, while built-in Kinds are only ever added upwards from Other,
+// so a registered Kind never collides with a built-in one, even in a
+// later version of this package.
+//
+// RegisterKind is meant to be called at init time, e.g. to set a
+// package level variable, and panics if name is empty or already
+// used by another Kind, or if no Kind values are left. Names are
+// compared case-insensitively, as by KindFromString, so a name
+// differing from another only in case is also a duplicate.
+func RegisterKind(name string, httpStatus int) Kind {
+	if name == "" {
+		panic("errs.RegisterKind: empty name")
+	}
+	for k := Other; k < lastKind; k++ {
+		if strings.EqualFold(k.String(), name) || strings.EqualFold(k.SnakeString(), name) {
+			panic("errs.RegisterKind: duplicate name " + name)
+		}
+	}
+
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	for _, ck := range customKinds {
+		if strings.EqualFold(ck.name, name) {
+			panic("errs.RegisterKind: duplicate name " + name)
+		}
+	}
+	if nextKind <= lastKind {
+		panic("errs.RegisterKind: too many registered Kinds")
+	}
+	k := nextKind
+	customKinds[k] = customKind{name: name, httpStatus: httpStatus}
+	nextKind--
+	return k
+}
+
+// lookupKind returns the description of a Kind registered through
+// RegisterKind
+func lookupKind(k Kind) (customKind, bool) {
+	kindsMu.RLock()
+	ck, ok := customKinds[k]
+	kindsMu.RUnlock()
+	return ck, ok
+}
+
+// KindFromString returns the Kind named s, the reverse of String and
+// SnakeString, and reports whether s was recognized. Kinds registered
+// through RegisterKind are included and the comparison is
+// case-insensitive. If s is not recognized, Other is returned.
+func KindFromString(s string) (Kind, bool) {
+	for k := Other; k < lastKind; k++ {
+		if strings.EqualFold(k.String(), s) || strings.EqualFold(k.SnakeString(), s) {
+			return k, true
+		}
+	}
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	for k, ck := range customKinds {
+		if strings.EqualFold(ck.name, s) || strings.EqualFold(snakeCase(ck.name), s) {
+			return k, true
+		}
+	}
+	return Other, false
+}
+
+// MarshalText implements encoding.TextMarshaler, a Kind is
+// encoded by name, as returned by String.
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the reverse of
+// MarshalText. A name which is not a known Kind decodes to
+// Unanticipated.
+func (k *Kind) UnmarshalText(text []byte) error {
+	kind, ok := KindFromString(string(text))
+	if !ok {
+		kind = Unanticipated
+	}
+	*k = kind
+	return nil
+}
+
 // E builds an error value from its arguments.
 // There must be at least one argument or E panics.
 // The type of each argument determines its meaning.
@@ -163,18 +466,39 @@ func (k Kind) String() string {
 //		The class of error, such as permission failure.
 //	error
 //		The underlying error that triggered this one.
+//	errors.RetryAfter
+//		How long the client should wait before retrying,
+//		sent in the Retry-After header.
+//	errors.UserMessage
+//		A safe message sent to the client instead of the
+//		error message.
+//	errors.Headers
+//		HTTP headers sent with the error response.
+//	errors.ParamValue
+//		The value of the parameter related to the error, as
+//		it was received, sent to the client.
+//	errors.Meta
+//		Metadata logged with the error as structured fields.
+//	errors.Status
+//		An explicit HTTP Status Code for the error, which
+//		takes precedence over the one of its Kind.
 //
 // If the error is printed, only those items that have been
 // set to non-zero values will appear in the result.
 //
 // If Kind is not specified or Other, we set it to the Kind of
-// the underlying error.
+// the underlying error. The same holds for Code and Param, so
+// wrapping an *Error with only an Op, as in E(Op("svc.Do"), err),
+// keeps the HTTP Status Code sent for it.
 //
 func E(args ...interface{}) error {
 	if len(args) == 0 {
 		panic("call to errors.E with no arguments")
 	}
-	e := &Error{}
+	e := &Error{stack: callers(3)}
+	if strictMode() {
+		checkConflicts(args)
+	}
 	for _, arg := range args {
 		switch arg := arg.(type) {
 		case PathName:
@@ -197,12 +521,32 @@ func E(args ...interface{}) error {
 			e.Code = arg
 		case Parameter:
 			e.Param = arg
+		case ParamValue:
+			e.ParamValue = arg
+		case RetryAfter:
+			e.RetryAfter = arg
+		case Status:
+			e.Status = arg
+		case UserMessage:
+			e.UserMessage = arg
+		case Headers:
+			e.Headers = arg
+		case Meta:
+			e.Meta = arg
 		default:
 			_, file, line, _ := runtime.Caller(1)
 			return fmt.Errorf("errors.E: bad call from %s:%d: %v, unknown type %T, value %v in error call", file, line, args, arg, arg)
 		}
 	}
 
+	// Without an error or message, use the message
+	// registered for the Code, if any
+	if e.Err == nil {
+		if rc, ok := lookupCode(e.Code); ok && rc.message != "" {
+			e.Err = errors.New(rc.message)
+		}
+	}
+
 	prev, ok := e.Err.(*Error)
 	if !ok {
 		return e
@@ -244,9 +588,299 @@ func E(args ...interface{}) error {
 		prev.Param = ""
 	}
 
+	if e.ParamValue == "" {
+		e.ParamValue = prev.ParamValue
+	}
+	prev.ParamValue = ""
+
+	if e.RetryAfter == 0 {
+		e.RetryAfter = prev.RetryAfter
+	}
+	prev.RetryAfter = 0
+
+	if e.Status == 0 {
+		e.Status = prev.Status
+	}
+	prev.Status = 0
+
+	if e.UserMessage == "" {
+		e.UserMessage = prev.UserMessage
+	}
+	prev.UserMessage = ""
+
+	// Pull up the inner Headers, this error's
+	// values win for headers set by both
+	if len(prev.Headers) > 0 {
+		headers := make(Headers, len(prev.Headers)+len(e.Headers))
+		for k, v := range prev.Headers {
+			headers[k] = v
+		}
+		for k, v := range e.Headers {
+			headers[k] = v
+		}
+		e.Headers = headers
+	}
+	prev.Headers = nil
+
+	// Pull up the inner Meta the same way
+	if len(prev.Meta) > 0 {
+		meta := make(Meta, len(prev.Meta)+len(e.Meta))
+		for k, v := range prev.Meta {
+			meta[k] = v
+		}
+		for k, v := range e.Meta {
+			meta[k] = v
+		}
+		e.Meta = meta
+	}
+	prev.Meta = nil
+
 	return e
 }
 
+var (
+	strictMu sync.RWMutex
+	// strict is the mode set through SetStrict
+	strict bool
+)
+
+// SetStrict turns strict mode on or off. In strict mode, E panics
+// when it is given conflicting arguments, which it otherwise
+// silently resolves by keeping the last one. Arguments conflict
+// when more than one of them sets the same element of the Error:
+//   - two arguments of the same type, such as two Kinds, two Codes
+//     or two Ops, even with equal values
+//   - two messages or errors, in any combination of string, error
+//     and *Error arguments, as they all set Err
+//
+// Strict mode is off by default and is meant to catch misuse of E
+// in tests. SetStrict is safe for concurrent use.
+func SetStrict(on bool) {
+	strictMu.Lock()
+	strict = on
+	strictMu.Unlock()
+}
+
+// strictMode reports whether strict mode is on, see SetStrict
+func strictMode() bool {
+	strictMu.RLock()
+	defer strictMu.RUnlock()
+	return strict
+}
+
+// checkConflicts panics if args holds conflicting arguments to E,
+// see SetStrict
+func checkConflicts(args []interface{}) {
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		var elem string
+		switch arg.(type) {
+		case string, error:
+			elem = "error"
+		default:
+			elem = fmt.Sprintf("%T", arg)
+		}
+		if seen[elem] {
+			panic(fmt.Sprintf("errors.E: conflicting arguments, more than one %s in %v", elem, args))
+		}
+		seen[elem] = true
+	}
+}
+
+// New returns an *Error with Kind Unanticipated and text as the
+// error message, recording the stack of its caller. It is the
+// counterpart of errors.New for code that needs no other elements.
+func New(text string) *Error {
+	return &Error{Kind: Unanticipated, Err: errors.New(text), stack: callers(3)}
+}
+
+// Wrap adds the operation op to err, keeping the Kind, Code and
+// Param of err if it is an *Error. It is shorthand for E(op, err),
+// use E to override any of them. As for WithStack, if err has no
+// error of this package in its chain, the Kind is Unanticipated. If
+// err is nil, Wrap returns nil.
+func Wrap(err error, op Op) error {
+	if err == nil {
+		return nil
+	}
+	e := E(op, err).(*Error)
+	if e.Kind == Other {
+		e.Kind = wrapKind(err)
+	}
+	e.stack = callers(3)
+	return e
+}
+
+// From returns an *Error of the given Kind wrapping err, typically
+// an error from the standard library or a third party package, such
+// as an *os.PathError, at the boundary where it is first classified.
+// err is kept as the Err of the returned *Error, so errors.Is and
+// errors.As still find it. It is shorthand for E(kind, err).
+//
+// From returns nil if err is nil. As the result is an *Error, not an
+// error, only call From with a non-nil err when returning it as an
+// error, otherwise the returned error is a non-nil interface holding
+// a nil *Error.
+func From(kind Kind, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := E(kind, err).(*Error)
+	e.stack = callers(3)
+	return e
+}
+
+// WithStack returns an *Error wrapping err which records the stack
+// of its caller, e.g. to locate where an error from the standard
+// library or a third party package, which has no stack, entered the
+// program. The Kind, Code, Param and message of err are kept. If err
+// has no error of this package in its chain, the Kind is set to
+// Unanticipated, as by New, so err is sent as an unexpected server
+// error and its message does not reach the client. As StackTrace
+// returns the innermost recorded stack, the stack of an *Error err
+// which already has one is still the one reported.
+//
+// WithStack returns nil if err is nil, see From for the caveat of
+// returning a nil *Error as an error.
+func WithStack(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := E(err).(*Error)
+	if e.Kind == Other {
+		e.Kind = wrapKind(err)
+	}
+	e.stack = callers(3)
+	return e
+}
+
+// wrapKind returns the Kind of an *Error which only wraps err:
+// Other if err has an error of this package in its chain, so its
+// Kind is the one reported, and Unanticipated otherwise
+func wrapKind(err error) Kind {
+	var pe packageError
+	if errors.As(err, &pe) {
+		return Other
+	}
+	return Unanticipated
+}
+
+// Wrapf is like Wrap, but also adds a message formatted according
+// to a format specifier, kept as an element of its own: Error writes
+// it after the operation and Message prepends it to the message of
+// err, e.g. "finding user 42: no such user" for
+//
+//	Wrapf(E(Op("repo.Find"), NotExist, "no such user"), Op("service.Find"), "finding user %d", 42)
+//
+// err remains in the chain for errors.Is and errors.As, and its Kind
+// is set as by Wrap. If err is nil, Wrapf returns nil.
+func Wrapf(err error, op Op, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	e := E(op, err).(*Error)
+	if e.Kind == Other {
+		e.Kind = wrapKind(err)
+	}
+	e.msg = fmt.Sprintf(format, args...)
+	e.stack = callers(3)
+	return e
+}
+
+// Errorf returns an *Error whose error message is formatted
+// according to a format specifier, recording the stack of its caller.
+// As with fmt.Errorf, an error operand of the %w verb is wrapped, so
+// errors.Is, errors.As and KindOf find it. If there is no %w verb,
+// the first error in args is wrapped instead of just being formatted.
+// If the wrapped error has a Kind, as reported by KindOf, the Kind is
+// left as Other, so functions such as KindOf and CodeOf report the
+// Kind and Code of the wrapped error. Otherwise the Kind is
+// Unanticipated, as for New, so the formatted message, which may hold
+// the text of an error from another package, does not reach the
+// client.
+func Errorf(format string, args ...interface{}) *Error {
+	err := errorf(format, args...)
+	kind := Other
+	if KindOf(err) == Other {
+		kind = Unanticipated
+	}
+	return &Error{Kind: kind, Err: err, stack: callers(3)}
+}
+
+// errorf formats an error like fmt.Errorf, wrapping the first error
+// in args if there is no %w verb, see Errorf
+func errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if !isWrapper(err) {
+		for _, arg := range args {
+			if argErr, ok := arg.(error); ok {
+				return &wrapError{msg: err.Error(), err: argErr}
+			}
+		}
+	}
+	return err
+}
+
+// isWrapper reports whether err wraps one or more errors
+func isWrapper(err error) bool {
+	switch err.(type) {
+	case interface{ Unwrap() error }, interface{ Unwrap() []error }:
+		return true
+	}
+	return false
+}
+
+// wrapError is an error message wrapping an underlying error, like
+// the error returned by fmt.Errorf with the %w verb
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string {
+	return e.msg
+}
+
+func (e *wrapError) Unwrap() error {
+	return e.err
+}
+
+// Message returns the message of the innermost error wrapped by e,
+// without the operations, kinds and other elements added by Error,
+// e.g. "no such user" for
+//
+//	E(Op("service.Find"), E(Op("repo.Find"), NotExist, "no such user"))
+//
+// *Error values wrapped by errors from other packages are searched
+// using errors.As. The message of ValidationErrors or of an error
+// returned by Join is the message of all their errors, as returned by
+// their Error method. The messages added by Wrapf along the chain are
+// prepended, separated by ": ". Message returns an empty string if
+// there is no message, e.g. for E(Op("repo.Find"), NotExist).
+func (e *Error) Message() string {
+	var msgs []string
+	for depth := 0; e != nil && depth < maxDepth; depth++ {
+		if e.msg != "" {
+			msgs = append(msgs, e.msg)
+		}
+		if e.Err == nil {
+			break
+		}
+		var pe packageError
+		if !errors.As(e.Err, &pe) {
+			msgs = append(msgs, e.Err.Error())
+			break
+		}
+		inner, ok := pe.(*Error)
+		if !ok {
+			msgs = append(msgs, pe.Error())
+			break
+		}
+		e = inner
+	}
+	return strings.Join(msgs, ": ")
+}
+
 // pad appends str to the buffer if the buffer already has some data.
 func pad(b *bytes.Buffer, str string) {
 	if b.Len() == 0 {
@@ -255,7 +889,66 @@ func pad(b *bytes.Buffer, str string) {
 	b.WriteString(str)
 }
 
+// maxDepth is the maximum number of nested *Error values walked in
+// the chain of an error, which guards against malformed chains, e.g.
+// an *Error wrapping itself, recursing indefinitely
+const maxDepth = 100
+
+// truncatedMarker replaces the nested errors beyond maxDepth in the
+// string returned by Error
+const truncatedMarker = "[error chain truncated]"
+
+var (
+	messageOnlyMu sync.RWMutex
+	// messageOnly is the mode set through SetMessageOnly
+	messageOnly bool
+)
+
+// SetMessageOnly turns message-only mode on or off. By default, Error
+// returns the operations, kinds and other elements of each *Error in
+// the chain, separated by Separator and "|: ", followed by the
+// message. In message-only mode, Error returns only the message, as
+// returned by Message, e.g. "no such user" for
+//
+//	E(Op("service.Find"), E(Op("repo.Find"), NotExist, "no such user"))
+//
+// or the Kind, as returned by KindOf, if there is no message; an
+// error with neither is formatted as by default. Log
+// parsers then get the human message without having to strip the
+// error stack details; the Ops, Kind and Code are still available to
+// structured logging, see MarshalJSON.
+//
+// SetMessageOnly is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetMessageOnly(on bool) {
+	messageOnlyMu.Lock()
+	messageOnly = on
+	messageOnlyMu.Unlock()
+}
+
+// messageOnlyMode reports whether message-only mode is on, see
+// SetMessageOnly
+func messageOnlyMode() bool {
+	messageOnlyMu.RLock()
+	defer messageOnlyMu.RUnlock()
+	return messageOnly
+}
+
 func (e *Error) Error() string {
+	if messageOnlyMode() {
+		if msg := e.Message(); msg != "" {
+			return msg
+		}
+		if k := KindOf(e); k != Other {
+			return k.String()
+		}
+	}
+	return e.error(0)
+}
+
+// error returns the string of e, nested depth levels deep in the
+// chain of an error
+func (e *Error) error(depth int) string {
 	b := new(bytes.Buffer)
 	if !e.StripError {
 		if e.Op != "" {
@@ -280,11 +973,18 @@ func (e *Error) Error() string {
 			b.WriteString(e.Kind.String())
 		}
 	}
+	if e.msg != "" {
+		pad(b, ": ")
+		b.WriteString(e.msg)
+	}
 	if e.Err != nil {
 		if prevErr, ok := e.Err.(*Error); ok {
-			if !prevErr.isZero() {
+			if depth+1 >= maxDepth {
+				pad(b, Separator)
+				b.WriteString(truncatedMarker)
+			} else if !prevErr.IsZero() {
 				pad(b, Separator)
-				b.WriteString(e.Err.Error())
+				b.WriteString(prevErr.error(depth + 1))
 			}
 		} else {
 			pad(b, "|: ")
@@ -297,6 +997,52 @@ func (e *Error) Error() string {
 	return b.String()
 }
 
+// MarshalJSON encodes e as a JSON object for structured logging,
+// with the Kind, Code and Param, the chain of operations from the
+// outermost to the innermost error and the error message without
+// the error stack details. Unset elements are omitted. The wrapped
+// errors are not marshaled themselves, only walked to build the
+// chain of operations.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind    string    `json:"kind,omitempty"`
+		Code    Code      `json:"code,omitempty"`
+		Param   Parameter `json:"param,omitempty"`
+		Path    PathName  `json:"path,omitempty"`
+		User    UserName  `json:"user,omitempty"`
+		Ops     []Op      `json:"ops,omitempty"`
+		Message string    `json:"message,omitempty"`
+	}{
+		Kind:    KindOf(e).String(),
+		Code:    CodeOf(e),
+		Param:   e.Param,
+		Path:    e.Path,
+		User:    e.User,
+		Ops:     Ops(e),
+		Message: stripStack(e),
+	})
+}
+
+// Ops returns the operations of the *Error values in the chain of
+// err, ordered from the outermost to the innermost, e.g.
+//
+//	["handler.CreateUser", "service.Create", "repo.Insert"]
+//
+// for request tracing. Empty operations are skipped, as is an
+// operation repeating the one before it. Ops returns nil if err
+// has no operations.
+func Ops(err error) []Op {
+	var ops []Op
+	var e *Error
+	for depth := 0; depth < maxDepth && errors.As(err, &e); depth++ {
+		if e.Op != "" && (len(ops) == 0 || ops[len(ops)-1] != e.Op) {
+			ops = append(ops, e.Op)
+		}
+		err = e.Err
+	}
+	return ops
+}
+
 // Match compares its two error arguments. It can be used to check
 // for expected errors in tests. Both arguments must have underlying
 // type *Error or Match will return false. Otherwise it returns true
@@ -307,6 +1053,12 @@ func (e *Error) Error() string {
 // Elements that are in the second argument but not present in
 // the first are ignored.
 //
+// The elements compared are Path, User, Op, Kind, Code, Param and
+// Err. Since only the elements set in the first argument are
+// compared, a template built without an Op, such as
+// E(Validation, Code("required")), matches regardless of the Op
+// (and error stack) of the second.
+//
 // For example,
 //	Match(errors.E(upspin.UserName("joe@schmoe.com"), errors.Permission), err)
 // tests whether err is an Error with Kind=Permission and User=joe@schmoe.com.
@@ -331,6 +1083,12 @@ func Match(err1, err2 error) bool {
 	if e1.Kind != Other && e2.Kind != e1.Kind {
 		return false
 	}
+	if e1.Code != "" && e2.Code != e1.Code {
+		return false
+	}
+	if e1.Param != "" && e2.Param != e1.Param {
+		return false
+	}
 	if e1.Err != nil {
 		if _, ok := e1.Err.(*Error); ok {
 			return Match(e1.Err, e2.Err)
@@ -342,18 +1100,171 @@ func Match(err1, err2 error) bool {
 	return true
 }
 
-// KindIs reports whether err is an *Error of the given Kind.
-// If err is nil then KindIs returns false.
+// KindIs reports whether err is an *Error of the given Kind. Like
+// KindOf, it searches the chain of errors wrapped by err using
+// errors.As, so the *Error may itself be wrapped by an error from
+// another package, and the first Kind that is not Other decides.
+// If err is nil or has no Kind, KindIs returns false, even for
+// Other.
 func KindIs(kind Kind, err error) bool {
-	e, ok := err.(*Error)
-	if !ok {
-		return false
+	return kind != Other && KindOf(err) == kind
+}
+
+// packageError is implemented by the errors of this package which
+// carry a Kind, *Error, ValidationErrors and the error returned by
+// Join, so the outermost of them in a chain is found by a single
+// errors.As
+type packageError interface {
+	error
+	packageError()
+}
+
+func (e *Error) packageError() {}
+
+// KindOf returns the Kind of err. It searches the chain of errors
+// wrapped by err for *Error values using errors.As, so the *Error
+// may itself be wrapped by an error from another package, and
+// returns the first Kind that is not Other. If there is none,
+// KindOf returns Other. The Kind of an error returned by Join is
+// the most severe Kind of the joined errors and the Kind of
+// ValidationErrors is Validation, including when they are wrapped by
+// another error.
+//
+// KindOf is the rule deciding which Kind wins when the levels of a
+// chain have different Kinds: the outermost one explicitly set,
+// falling back inward. HTTPErrorResponse, StatusCode and the Status
+// function of package errsgrpc use it, so re-wrapping an error never
+// changes its Kind unless the wrapper sets one.
+func KindOf(err error) Kind {
+	var pe packageError
+	for depth := 0; depth < maxDepth && errors.As(err, &pe); depth++ {
+		switch e := pe.(type) {
+		case *joinError:
+			return e.kind
+		case ValidationErrors:
+			return Validation
+		case *Error:
+			if e.Kind != Other {
+				return e.Kind
+			}
+			err = e.Err
+		}
 	}
-	if e.Kind != Other {
-		return e.Kind == kind
+	return Other
+}
+
+// CodeOf returns the Code of err. Like KindOf, it searches the chain
+// of errors wrapped by err for *Error values using errors.As and
+// returns the first Code that is not empty. If there is none,
+// CodeOf returns an empty Code.
+func CodeOf(err error) Code {
+	var e *Error
+	for depth := 0; depth < maxDepth && errors.As(err, &e); depth++ {
+		if e.Code != "" {
+			return e.Code
+		}
+		err = e.Err
 	}
-	if e.Err != nil {
-		return KindIs(kind, e.Err)
+	return ""
+}
+
+// HasCode reports whether the Code of err, as returned by CodeOf,
+// is code.
+func HasCode(err error, code Code) bool {
+	return code != "" && CodeOf(err) == code
+}
+
+// Signature returns a stable key identifying the class of err, made
+// of its Kind and Code as returned by KindOf and CodeOf, e.g.
+// "database_error:conn_refused", or only the Kind if there is no
+// Code. The Param and the message are excluded, as their values are
+// unbounded, so the Signature is suitable as a metric label or a map
+// key grouping similar errors. Signature returns an empty string if
+// err is nil.
+func Signature(err error) string {
+	if err == nil {
+		return ""
+	}
+	sig := KindOf(err).String()
+	if code := CodeOf(err); code != "" {
+		sig += ":" + string(code)
+	}
+	return sig
+}
+
+// temporaryKinds are the Kinds of errors which are usually
+// temporary, i.e. worth retrying
+var temporaryKinds = map[Kind]bool{
+	IO:              true,
+	Database:        true,
+	TooManyRequests: true,
+	Timeout:         true,
+	Unavailable:     true,
+}
+
+// IsTemporary reports whether err is likely temporary, i.e. whether
+// the operation that failed is worth retrying. The Kind of err, as
+// returned by KindOf, decides: IO and Database errors, such as a
+// network failure or a lost database connection, TooManyRequests,
+// Timeout and Unavailable errors are temporary, all other Kinds are
+// not. If the Kind is Other, err is temporary
+// if any error in its chain has a Temporary method reporting true,
+// as net.Error does.
+func IsTemporary(err error) bool {
+	kind := KindOf(err)
+	if kind != Other {
+		return temporaryKinds[kind]
+	}
+	// walk the chain with errors.Unwrap rather than errors.As, so
+	// it is bounded by maxDepth
+	for depth := 0; err != nil && depth < maxDepth; depth++ {
+		if t, ok := err.(interface{ Temporary() bool }); ok {
+			return t.Temporary()
+		}
+		err = errors.Unwrap(err)
 	}
 	return false
 }
+
+// maxStackDepth is the maximum number of stack frames recorded
+// for an error
+const maxStackDepth = 32
+
+// callers returns the program counters of the calling goroutine's
+// stack, skipping the first skip frames as runtime.Callers does,
+// e.g. a skip of 3 from within E starts the stack at E's caller.
+func callers(skip int) []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	return pcs[:n]
+}
+
+// StackTrace returns the stack frames where err was created. If err
+// is a chain of nested *Error values, the stack of the innermost
+// *Error is returned, as that is closest to the origin of the
+// failure. StackTrace returns nil if err has no *Error with a
+// recorded stack.
+func StackTrace(err error) []runtime.Frame {
+	var pcs []uintptr
+	var e *Error
+	for depth := 0; depth < maxDepth && errors.As(err, &e); depth++ {
+		if len(e.stack) > 0 {
+			pcs = e.stack
+		}
+		err = e.Err
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	trace := make([]runtime.Frame, 0, len(pcs))
+	for {
+		frame, more := frames.Next()
+		trace = append(trace, frame)
+		if !more {
+			break
+		}
+	}
+	return trace
+}
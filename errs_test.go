@@ -5,9 +5,22 @@
 package errs
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
 )
 
 func TestSeparator(t *testing.T) {
@@ -75,12 +88,17 @@ func TestMatch(t *testing.T) {
 		{E(op, Invalid, io.EOF), E(op, Invalid, io.EOF, jane, path1), true},
 		{E(op, Invalid), E(op, Invalid, io.EOF, jane, path1), true},
 		{E(op), E(op, Invalid, io.EOF, jane, path1), true},
+		{E(Invalid, Code("c1"), Parameter("p1")), E(op, Invalid, Code("c1"), Parameter("p1"), io.EOF), true},
+		{E(Invalid), E(op1, Invalid, Code("c1"), Parameter("p1")), true},
 		// Failure.
 		{E(io.EOF), E(io.ErrClosedPipe), false},
 		{E(op1), E(op2), false},
 		{E(Invalid), E(Permission), false},
 		{E(jane), E(john), false},
 		{E(path1), E(path2), false},
+		{E(Code("c1")), E(Code("c2")), false},
+		{E(Parameter("p1")), E(Parameter("p2")), false},
+		{E(Invalid, Code("c1")), E(op, Invalid), false},
 		{E(op, Invalid, io.EOF, jane, path1), E(op, Invalid, io.EOF, john, path1), false},
 		{E(path1, errors.New("something")), E(path1), false}, // Test nil error on rhs.
 		// Nested *Errors.
@@ -119,6 +137,11 @@ func TestKind(t *testing.T) {
 		{E("Nesting", E(Exist)), NotExist, false},
 		{E("Nesting", E("no kind")), NotExist, false},
 		{E("Nesting", E("no kind")), Other, false},
+		// *Error values wrapped by other errors.
+		{fmt.Errorf("wrapped: %w", E(NotExist)), NotExist, true},
+		{fmt.Errorf("wrapped: %w", E(Exist)), NotExist, false},
+		{fmt.Errorf("wrapped: %w", E("Nesting", fmt.Errorf("wrapped: %w", E(NotExist)))), NotExist, true},
+		{BazError{Reason: "baz", Inner: E(NotExist)}, NotExist, true},
 	}
 
 	for _, test := range kindTests {
@@ -128,3 +151,824 @@ func TestKind(t *testing.T) {
 		}
 	}
 }
+
+// TestKind_String locks down the strings of the built-in Kinds,
+// which are part of the wire contract with clients
+func TestKind_String(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want string
+	}{
+		{Other, "other_error"},
+		{Invalid, "invalid_operation"},
+		{Permission, "permission_denied"},
+		{IO, "I/O_error"},
+		{Exist, "item_already_exists"},
+		{NotExist, "item_does_not_exist"},
+		{Private, "information_withheld"},
+		{Internal, "internal_error"},
+		{BrokenLink, "link_target_does_not_exist"},
+		{Database, "database_error"},
+		{Validation, "input_validation_error"},
+		{Unanticipated, "unanticipated_error"},
+		{InvalidRequest, "invalid_request_error"},
+		{Unauthenticated, "unauthenticated"},
+		{Unauthorized, "unauthorized"},
+		{TooManyRequests, "too_many_requests"},
+		{Timeout, "timeout"},
+		{MethodNotAllowed, "method_not_allowed"},
+		{Canceled, "canceled"},
+		{Unavailable, "unavailable"},
+		{Conflict, "conflict"},
+	}
+	if len(tests) != int(lastKind) {
+		t.Fatalf("%d Kinds tested, want all %d built-in Kinds", len(tests), lastKind)
+	}
+	for _, tt := range tests {
+		if got := tt.kind.String(); got != tt.want {
+			t.Errorf("Kind(%d).String() = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+	snake := regexp.MustCompile(`^[a-z]+(_[a-z]+)*$`)
+	for k := Other; k < lastKind; k++ {
+		if k != IO && !snake.MatchString(k.String()) {
+			t.Errorf("Kind(%d).String() = %q, want snake_case", k, k.String())
+		}
+	}
+	if KindNotExistString != NotExist.String() {
+		t.Errorf("KindNotExistString = %q, want %q", KindNotExistString, NotExist.String())
+	}
+}
+
+// quotaExceeded is registered once for all tests with a name which
+// is not snake_case
+var quotaExceeded = RegisterKind("QuotaExceeded", http.StatusTooManyRequests)
+
+func TestKind_SnakeString(t *testing.T) {
+	snake := regexp.MustCompile(`^[a-z]+(_[a-z]+)*$`)
+	for k := Other; k < lastKind; k++ {
+		if got := k.SnakeString(); !snake.MatchString(got) {
+			t.Errorf("Kind(%d).SnakeString() = %q, want snake_case", k, got)
+		}
+		if k != IO && k.SnakeString() != k.String() {
+			t.Errorf("Kind(%d).SnakeString() = %q, want %q", k, k.SnakeString(), k.String())
+		}
+	}
+
+	tests := []struct {
+		kind Kind
+		want string
+	}{
+		{IO, "io_error"},
+		{rateLimited, "rate_limited"},
+		{quotaExceeded, "quota_exceeded"},
+	}
+	for _, tt := range tests {
+		if got := tt.kind.SnakeString(); got != tt.want {
+			t.Errorf("%s.SnakeString() = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ in, want string }{
+		{"Rate Limited", "rate_limited"},
+		{"rate-limited!", "rate_limited"},
+		{"__quota__", "quota"},
+		{"Tier2Limit", "tier2_limit"},
+	} {
+		if got := snakeCase(tt.in); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	err := E(Op("repo.Find"), NotExist, sql.ErrNoRows)
+	err = E(Op("service.Find"), err)
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("errors.Is(%q, sql.ErrNoRows) = false, want true", err)
+	}
+	if errors.Is(err, io.EOF) {
+		t.Errorf("errors.Is(%q, io.EOF) = true, want false", err)
+	}
+
+	pathErr := &os.PathError{Op: "open", Path: "/no/such/file", Err: os.ErrNotExist}
+	err = E(Op("service.Open"), E(Op("repo.Open"), IO, pathErr))
+
+	var pe *os.PathError
+	if !errors.As(err, &pe) {
+		t.Fatalf("errors.As(%q, *os.PathError) = false, want true", err)
+	}
+	if pe.Path != pathErr.Path {
+		t.Errorf("errors.As() Path = %q, want %q", pe.Path, pathErr.Path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("errors.Is(%q, os.ErrNotExist) = false, want true", err)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"nil", nil, Other},
+		{"Non-Error", errors.New("not an *Error"), Other},
+		{"No Kind", E("no kind"), Other},
+		{"Single", E(NotExist), NotExist},
+		{"Nested", E(Op("outer"), E(Op("inner"), Database, "connection refused")), Database},
+		{"Outer Kind Wins", E(Op("outer"), Validation, E(Op("inner"), Database)), Validation},
+		{"Kind Below Other", &Error{Op: "outer", Err: &Error{Op: "inner", Kind: Permission}}, Permission},
+		{"Wrapped by fmt.Errorf", fmt.Errorf("lookup: %w", E(NotExist)), NotExist},
+		{"Mixed Chain", E(Op("outer"), fmt.Errorf("lookup: %w", E(Op("inner"), IO))), IO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInspection_WrappedByFmt checks that the inspection helpers find
+// an *Error wrapped by another package with fmt.Errorf and %w
+func TestInspection_WrappedByFmt(t *testing.T) {
+	inner := E(Op("repo.Find"), Database, Code("db_down"), "connection refused")
+	err := fmt.Errorf("find user: %w", inner)
+
+	if got := KindOf(err); got != Database {
+		t.Errorf("KindOf() = %v, want %v", got, Database)
+	}
+	if !KindIs(Database, err) {
+		t.Errorf("KindIs(Database) = false, want true")
+	}
+	if got := CodeOf(err); got != "db_down" {
+		t.Errorf("CodeOf() = %q, want %q", got, "db_down")
+	}
+	if !HasCode(err, "db_down") {
+		t.Errorf("HasCode(db_down) = false, want true")
+	}
+	if !IsTemporary(err) {
+		t.Errorf("IsTemporary() = false, want true")
+	}
+	if got, want := Ops(err), []Op{"repo.Find"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ops() = %v, want %v", got, want)
+	}
+	if len(StackTrace(err)) == 0 {
+		t.Errorf("StackTrace() returned no frames")
+	}
+	if got := Classify(err).Kind; got != Database {
+		t.Errorf("Classify().Kind = %v, want %v", got, Database)
+	}
+	if got := KindOf(Join(err, E(NotExist, "no such user"))); got != Database {
+		t.Errorf("KindOf(Join()) = %v, want %v", got, Database)
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	errCardDeclined := &Error{Code: "card_declined"}
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"Kind", NotFound("id", "no such user"), ErrNotFound, true},
+		{"Other Kind", NotFound("id", "no such user"), ErrAlreadyExists, false},
+		{"Wrapped", E(Op("service.Find"), fmt.Errorf("find: %w", E(NotExist, "no such user"))), ErrNotFound, true},
+		{"Code", E(Invalid, Code("card_declined"), "declined"), errCardDeclined, true},
+		{"Other Code", E(Invalid, Code("card_expired"), "expired"), errCardDeclined, false},
+		{"Kind and Code", E(Invalid, Code("card_declined"), "declined"), &Error{Kind: Invalid, Code: "card_declined"}, true},
+		{"Kind but not Code", E(Invalid, "declined"), &Error{Kind: Invalid, Code: "card_declined"}, false},
+		{"Empty Target", E(Invalid, "declined"), &Error{}, false},
+		{"Target with Err", E(Invalid, "declined"), E(Invalid, "declined"), false},
+		{"Not an Error", errors.New("no such user"), ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithStack(t *testing.T) {
+	e := WithStack(sql.ErrNoRows)
+	if got, want := e.Message(), sql.ErrNoRows.Error(); got != want {
+		t.Errorf("WithStack().Message() = %q, want %q", got, want)
+	}
+	if e.Kind != Unanticipated {
+		t.Errorf("WithStack().Kind = %v, want %v", e.Kind, Unanticipated)
+	}
+	if !errors.Is(e, sql.ErrNoRows) {
+		t.Errorf("errors.Is(WithStack(), sql.ErrNoRows) = false, want true")
+	}
+	if frames := StackTrace(e); len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "TestWithStack") {
+		t.Errorf("StackTrace(WithStack()) = %v, want the caller of WithStack first", frames)
+	}
+
+	e = WithStack(&Error{Kind: NotExist, Code: "0404", Err: errors.New("no such user")})
+	if e.Kind != NotExist || e.Code != "0404" || e.Message() != "no such user" {
+		t.Errorf("WithStack() = %+v, want the Kind, Code and message kept", e)
+	}
+
+	e = WithStack(E(Op("repo.Find"), "no such user"))
+	if e.Kind != Other || e.Message() != "no such user" {
+		t.Errorf("WithStack() = %+v, want the Kind of the *Error kept", e)
+	}
+
+	if WithStack(nil) != nil {
+		t.Errorf("WithStack(nil) != nil")
+	}
+}
+
+func TestSelfReferentialChain(t *testing.T) {
+	e := &Error{Op: "loop", Err: errors.New("placeholder")}
+	e.Err = e
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := e.Error(); !strings.HasSuffix(got, truncatedMarker) {
+			t.Errorf("Error() = %q, want it truncated", got)
+		}
+		if got := stripStack(e); !strings.HasSuffix(got, truncatedMarker) {
+			t.Errorf("stripStack() = %q, want it truncated", got)
+		}
+		if got := e.Message(); got != "" {
+			t.Errorf("Message() = %q, want none", got)
+		}
+		if got := KindOf(e); got != Other {
+			t.Errorf("KindOf() = %v, want %v", got, Other)
+		}
+		if got := CodeOf(e); got != "" {
+			t.Errorf("CodeOf() = %q, want none", got)
+		}
+		if got, want := Ops(e), []Op{"loop"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Ops() = %v, want %v", got, want)
+		}
+		if got := StackTrace(e); got != nil {
+			t.Errorf("StackTrace() = %v, want nil", got)
+		}
+		if got := len(causes(e)); got != maxDepth {
+			t.Errorf("len(causes()) = %d, want %d", got, maxDepth)
+		}
+		w := httptest.NewRecorder()
+		HTTPErrorResponse(w, zerolog.Nop(), e)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("walking a self-referential chain did not terminate")
+	}
+}
+
+func TestError_IsZero(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want bool
+	}{
+		{"nil", nil, true},
+		{"empty", &Error{}, true},
+		{"Code and Param only", &Error{Code: "0212", Param: "id"}, true},
+		{"Op", &Error{Op: "repo.Find"}, false},
+		{"Kind", &Error{Kind: NotExist}, false},
+		{"Err", &Error{Err: errors.New("boom")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.IsZero(); got != tt.want {
+				t.Errorf("IsZero() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestE_PullsUpKindCodeParam(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Code("user_not_found"), Parameter("id"), "no such user")
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Op only", E(Op("svc.Do"), inner)},
+		{"twice", E(Op("handler.Get"), E(Op("svc.Do"), inner))},
+		{"Wrap", Wrap(inner, "svc.Do")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.err.(*Error)
+			if e.Kind != NotExist || e.Code != "user_not_found" || e.Param != "id" {
+				t.Errorf("Kind, Code, Param = %v, %q, %q, want %v, %q, %q", e.Kind, e.Code, e.Param, NotExist, "user_not_found", "id")
+			}
+			if got := e.StatusCode(); got != http.StatusNotFound {
+				t.Errorf("StatusCode() = %d, want %d", got, http.StatusNotFound)
+			}
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+		})
+	}
+
+	// an explicit Kind is not replaced by the inner one
+	if e := E(Op("svc.Do"), Internal, inner).(*Error); e.Kind != Internal {
+		t.Errorf("Kind = %v, want %v", e.Kind, Internal)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Code("user_not_found"), Parameter("id"), sql.ErrNoRows)
+
+	err := Wrap(inner, Op("service.Find"))
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Wrap() returned %T, want *Error", err)
+	}
+	if e.Op != "service.Find" || e.Kind != NotExist || e.Code != "user_not_found" || e.Param != "id" {
+		t.Errorf("Wrap() = %+v, want Op, Kind, Code and Param set", e)
+	}
+	want := "service.Find: item_does_not_exist] repo.Find|: sql: no rows in result set"
+	if err.Error() != want {
+		t.Errorf("Wrap() = %q, want %q", err, want)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("errors.Is(Wrap(), sql.ErrNoRows) = false, want true")
+	}
+
+	if got := KindOf(Wrap(sql.ErrNoRows, Op("repo.Find"))); got != Unanticipated {
+		t.Errorf("KindOf(Wrap(plain error)) = %v, want %v", got, Unanticipated)
+	}
+
+	if Wrap(nil, Op("service.Find")) != nil {
+		t.Error("Wrap(nil) != nil")
+	}
+}
+
+func TestFrom(t *testing.T) {
+	_, perr := os.Open("/no/such/file")
+
+	e := From(NotExist, perr)
+	if e.Kind != NotExist {
+		t.Errorf("From().Kind = %v, want %v", e.Kind, NotExist)
+	}
+	if !errors.Is(e, os.ErrNotExist) {
+		t.Error("errors.Is(From(), os.ErrNotExist) = false, want true")
+	}
+	var pathErr *os.PathError
+	if !errors.As(e, &pathErr) || pathErr.Path != "/no/such/file" {
+		t.Errorf("errors.As(From(), *os.PathError) = %v, want the wrapped error", pathErr)
+	}
+	if frames := StackTrace(e); len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "TestFrom") {
+		t.Errorf("StackTrace(From()) = %v, want the caller of From first", frames)
+	}
+
+	if From(Database, nil) != nil {
+		t.Error("From(nil) != nil")
+	}
+}
+
+func TestWrapf(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Code("user_not_found"), sql.ErrNoRows)
+
+	err := Wrapf(inner, Op("service.Find"), "finding user %d", 42)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Wrapf() returned %T, want *Error", err)
+	}
+	if e.Op != "service.Find" || e.Kind != NotExist || e.Code != "user_not_found" {
+		t.Errorf("Wrapf() = %+v, want Op, Kind and Code set", e)
+	}
+	want := "service.Find: item_does_not_exist: finding user 42] repo.Find|: sql: no rows in result set"
+	if err.Error() != want {
+		t.Errorf("Wrapf() = %q, want %q", err, want)
+	}
+	wantMsg := "finding user 42: sql: no rows in result set"
+	if got := e.Message(); got != wantMsg {
+		t.Errorf("Wrapf().Message() = %q, want %q", got, wantMsg)
+	}
+	if got := stripStack(e); got != wantMsg {
+		t.Errorf("stripStack(Wrapf()) = %q, want %q", got, wantMsg)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("errors.Is(Wrapf(), sql.ErrNoRows) = false, want true")
+	}
+	if !Match(E(Op("repo.Find")), e.Err) {
+		t.Errorf("Wrapf() lost the wrapped *Error: %v", e.Err)
+	}
+
+	// the message of an error not from this package is kept the same way
+	plain := Wrapf(sql.ErrNoRows, Op("repo.Find"), "finding user %d", 42).(*Error)
+	if got, want := plain.Error(), "repo.Find: unanticipated_error: finding user 42|: sql: no rows in result set"; got != want {
+		t.Errorf("Wrapf(plain) = %q, want %q", got, want)
+	}
+	if got := plain.Message(); got != wantMsg {
+		t.Errorf("Wrapf(plain).Message() = %q, want %q", got, wantMsg)
+	}
+
+	// messages added along the chain are all kept, outermost first
+	twice := Wrapf(err, Op("handler.Get"), "GET /users/42").(*Error)
+	if got, want := twice.Message(), "GET /users/42: "+wantMsg; got != want {
+		t.Errorf("Wrapf(Wrapf()).Message() = %q, want %q", got, want)
+	}
+
+	if Wrapf(nil, Op("service.Find"), "finding user %d", 42) != nil {
+		t.Error("Wrapf(nil) != nil")
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"Non-Error", errors.New("not an *Error"), false},
+		{"IO", E(IO, "network unreachable"), true},
+		{"Database", E(Op("repo.Find"), Database, "connection lost"), true},
+		{"Validation", E(Validation, "bad input"), false},
+		{"Nested", E(Op("service.Find"), E(Op("repo.Find"), IO)), true},
+		{"Wrapped by fmt.Errorf", fmt.Errorf("find: %w", E(Database)), true},
+		{"Temporary net.Error", E(Op("client.Get"), &net.DNSError{Err: "timeout", IsTemporary: true}), true},
+		{"Permanent net.Error", E(Op("client.Get"), &net.DNSError{Err: "no such host"}), false},
+		{"Timeout", E(Op("client.Get"), Timeout, "upstream timed out"), true},
+		{"Kind Wins over Temporary", E(Validation, &net.DNSError{IsTemporary: true}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Errorf("IsTemporary(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func stackTraceLayer2() error {
+	return E(Op("layer2"), IO, "network unreachable")
+}
+
+func stackTraceLayer1() error {
+	return E(Op("layer1"), stackTraceLayer2())
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"E", stackTraceLayer1(), "errs.stackTraceLayer2"},
+		{"Wrap", Wrap(errors.New("plain"), Op("wrap")), "errs.TestStackTrace"},
+		{"Wrapf", Wrapf(errors.New("plain"), Op("wrap"), "msg"), "errs.TestStackTrace"},
+		{"New", New("something went wrong"), "errs.TestStackTrace"},
+		{"Errorf", Errorf("user %d", 42), "errs.TestStackTrace"},
+		{"NewValidation", NewValidation("email", "email is invalid"), "errs.TestStackTrace"},
+		{"Validationf", Validationf("email", "email is invalid"), "errs.TestStackTrace"},
+		{"Wrapped by fmt.Errorf", fmt.Errorf("x: %w", stackTraceLayer2()), "errs.stackTraceLayer2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := StackTrace(tt.err)
+			if len(frames) == 0 {
+				t.Fatal("StackTrace() returned no frames")
+			}
+			if !strings.HasSuffix(frames[0].Function, tt.want) {
+				t.Errorf("StackTrace()[0].Function = %q, want suffix %q", frames[0].Function, tt.want)
+			}
+		})
+	}
+
+	if frames := StackTrace(errors.New("plain")); frames != nil {
+		t.Errorf("StackTrace(non-Error) = %v, want nil", frames)
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, ""},
+		{"Non-Error", errors.New("email_taken"), ""},
+		{"No Code", E(Exist, "no code"), ""},
+		{"Single", E(Exist, Code("email_taken")), "email_taken"},
+		{"Nested", E(Op("service.Create"), E(Op("repo.Insert"), Exist, Code("email_taken"))), "email_taken"},
+		{"Code Below Empty", &Error{Op: "outer", Err: &Error{Op: "inner", Code: "email_taken"}}, "email_taken"},
+		{"Outer Code Wins", E(Code("signup_failed"), E(Code("email_taken"))), "signup_failed"},
+		{"Wrapped by fmt.Errorf", fmt.Errorf("create: %w", E(Code("email_taken"))), "email_taken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+			if tt.want != "" && !HasCode(tt.err, tt.want) {
+				t.Errorf("HasCode(%v, %q) = false, want true", tt.err, tt.want)
+			}
+			if HasCode(tt.err, "other_code") {
+				t.Errorf("HasCode(%v, %q) = true, want false", tt.err, "other_code")
+			}
+		})
+	}
+
+	if HasCode(E("no code"), "") {
+		t.Error(`HasCode(err, "") = true, want false`)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"Non-Error", errors.New("boom"), "other_error"},
+		{"No Code", E(Exist, "no code"), "item_already_exists"},
+		{"Kind and Code", E(Database, Code("conn_refused"), "dial tcp: connection refused"), "database_error:conn_refused"},
+		{"Param and Message Excluded", E(Exist, Code("email_taken"), Parameter("email"), "gilcrest@example.com is taken"), "item_already_exists:email_taken"},
+		{"Nested", E(Op("service.Create"), E(Op("repo.Insert"), Database, Code("conn_refused"))), "database_error:conn_refused"},
+		{"Wrapped by fmt.Errorf", fmt.Errorf("create: %w", E(Timeout, Code("slow_query"))), "timeout:slow_query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Signature(tt.err); got != tt.want {
+				t.Errorf("Signature(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_MarshalJSON(t *testing.T) {
+	err := E(Op("service.Create"), E(Op("repo.Insert"), Exist, Code("email_taken"), Parameter("email"), "email already registered"))
+
+	b, jerr := json.Marshal(err)
+	if jerr != nil {
+		t.Fatalf("json.Marshal() error = %v", jerr)
+	}
+	want := `{"kind":"item_already_exists","code":"email_taken","param":"email","ops":["service.Create","repo.Insert"],"message":"email already registered"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	b, jerr = json.Marshal(E("no kind"))
+	if jerr != nil {
+		t.Fatalf("json.Marshal() error = %v", jerr)
+	}
+	want = `{"kind":"other_error","message":"no kind"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+}
+
+func TestOps(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []Op
+	}{
+		{"nil", nil, nil},
+		{"Non-Error", errors.New("not an *Error"), nil},
+		{"No Op", E(NotExist), nil},
+		{"Single", E(Op("repo.Insert"), Exist), []Op{"repo.Insert"}},
+		{"Chain", E(Op("handler.CreateUser"), E(Op("service.Create"), E(Op("repo.Insert"), Exist))),
+			[]Op{"handler.CreateUser", "service.Create", "repo.Insert"}},
+		{"Skip Empty", E(Op("handler.CreateUser"), E(Exist, E(Op("repo.Insert")))),
+			[]Op{"handler.CreateUser", "repo.Insert"}},
+		{"Skip Duplicate", E(Op("service.Create"), E(Op("service.Create"), E(Op("repo.Insert")))),
+			[]Op{"service.Create", "repo.Insert"}},
+		{"Wrapped by fmt.Errorf", E(Op("handler.CreateUser"), fmt.Errorf("create: %w", E(Op("repo.Insert")))),
+			[]Op{"handler.CreateUser", "repo.Insert"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Ops(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ops(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	err := New("something went wrong")
+	if err.Kind != Unanticipated {
+		t.Errorf("New().Kind = %v, want %v", err.Kind, Unanticipated)
+	}
+	want := "unanticipated_error|: something went wrong"
+	if err.Error() != want {
+		t.Errorf("New() = %q, want %q", err, want)
+	}
+	if !KindIs(Unanticipated, err) {
+		t.Errorf("KindIs(Unanticipated, New()) = false, want true")
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Code("user_not_found"), sql.ErrNoRows)
+
+	tests := []struct {
+		name     string
+		err      *Error
+		wantKind Kind
+		wantMsg  string
+		wrapped  error
+	}{
+		{"No Error", Errorf("user %d not found", 42), Unanticipated, "user 42 not found", nil},
+		{"%w", Errorf("finding user %d: %w", 42, sql.ErrNoRows), Unanticipated, "finding user 42: sql: no rows in result set", sql.ErrNoRows},
+		{"%v Error", Errorf("finding user %d: %v", 42, sql.ErrNoRows), Unanticipated, "finding user 42: sql: no rows in result set", sql.ErrNoRows},
+		{"%w *Error", Errorf("finding user: %w", inner), Other, "finding user: " + inner.Error(), sql.ErrNoRows},
+		{"%w *Error without Kind", Errorf("finding user: %w", E(Op("repo.Find"), "no such user")), Unanticipated, "finding user: repo.Find|: no such user", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Kind != tt.wantKind {
+				t.Errorf("Errorf().Kind = %v, want %v", tt.err.Kind, tt.wantKind)
+			}
+			if got := tt.err.Err.Error(); got != tt.wantMsg {
+				t.Errorf("Errorf() message = %q, want %q", got, tt.wantMsg)
+			}
+			if tt.wrapped != nil && !errors.Is(tt.err, tt.wrapped) {
+				t.Errorf("errors.Is(Errorf(), %v) = false, want true", tt.wrapped)
+			}
+		})
+	}
+
+	err := Errorf("finding user: %w", inner)
+	if KindOf(err) != NotExist || CodeOf(err) != "user_not_found" {
+		t.Errorf("KindOf, CodeOf(Errorf()) = %v, %q, want the wrapped Kind and Code", KindOf(err), CodeOf(err))
+	}
+}
+
+// rateLimited is registered once for all tests, as RegisterKind
+// cannot be undone
+var rateLimited = RegisterKind("rate_limited", http.StatusTooManyRequests)
+
+func TestRegisterKind(t *testing.T) {
+	if rateLimited < lastKind {
+		t.Fatalf("RegisterKind() = %d, collides with built-in Kinds", rateLimited)
+	}
+	if got := rateLimited.String(); got != "rate_limited" {
+		t.Errorf("String() = %q, want %q", got, "rate_limited")
+	}
+	if got := statusCode(rateLimited); got != http.StatusTooManyRequests {
+		t.Errorf("statusCode() = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if !KindIs(rateLimited, E(Op("api.Call"), rateLimited, "slow down")) {
+		t.Error("KindIs(rateLimited) = false, want true")
+	}
+
+	for _, name := range []string{"", "rate_limited", "Rate_Limited", NotExist.String(), "Timeout", "ITEM_DOES_NOT_EXIST"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterKind(%q) did not panic", name)
+				}
+			}()
+			RegisterKind(name, http.StatusTeapot)
+		}()
+	}
+}
+
+func TestKind_MarshalText(t *testing.T) {
+	type record struct {
+		Kind Kind `json:"kind"`
+	}
+	for _, k := range []Kind{Other, NotExist, Validation, Timeout, rateLimited} {
+		b, err := json.Marshal(record{Kind: k})
+		if err != nil {
+			t.Fatalf("json.Marshal(%v) error = %v", k, err)
+		}
+		if want := `{"kind":"` + k.String() + `"}`; string(b) != want {
+			t.Errorf("json.Marshal(%v) = %s, want %s", k, b, want)
+		}
+		var got record
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", b, err)
+		}
+		if got.Kind != k {
+			t.Errorf("json.Unmarshal(%s) = %v, want %v", b, got.Kind, k)
+		}
+	}
+
+	var got record
+	if err := json.Unmarshal([]byte(`{"kind":"no_such_kind"}`), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got.Kind != Unanticipated {
+		t.Errorf("json.Unmarshal() = %v, want %v", got.Kind, Unanticipated)
+	}
+}
+
+func TestKindFromString(t *testing.T) {
+	for k := Other; k < lastKind; k++ {
+		for _, s := range []string{k.String(), strings.ToUpper(k.String()), k.SnakeString()} {
+			got, ok := KindFromString(s)
+			if !ok || got != k {
+				t.Errorf("KindFromString(%q) = %v, %t, want %v, true", s, got, ok, k)
+			}
+		}
+	}
+	if got, ok := KindFromString("Rate_Limited"); !ok || got != rateLimited {
+		t.Errorf("KindFromString(%q) = %v, %t, want %v, true", "Rate_Limited", got, ok, rateLimited)
+	}
+	if got, ok := KindFromString("quota_exceeded"); !ok || got != quotaExceeded {
+		t.Errorf("KindFromString(%q) = %v, %t, want %v, true", "quota_exceeded", got, ok, quotaExceeded)
+	}
+	if got, ok := KindFromString("no_such_kind"); ok || got != Other {
+		t.Errorf("KindFromString(%q) = %v, %t, want %v, false", "no_such_kind", got, ok, Other)
+	}
+}
+
+func TestError_Message(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{"message", E(Op("repo.Find"), NotExist, "no such user").(*Error), "no such user"},
+		{"nested", E(Op("service.Find"), E(Op("repo.Find"), NotExist, "no such user")).(*Error), "no such user"},
+		{"no inner Op", E(Op("service.Find"), E(NotExist, "no such user")).(*Error), "no such user"},
+		{"error", E(Op("repo.Find"), sql.ErrNoRows).(*Error), sql.ErrNoRows.Error()},
+		{"wrapped *Error", E(Op("service.Find"), fmt.Errorf("find: %w", E(Op("repo.Find"), "no such user"))).(*Error), "no such user"},
+		{"no message", E(Op("repo.Find"), NotExist).(*Error), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Message(); got != tt.want {
+				t.Errorf("Message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetMessageOnly(t *testing.T) {
+	SetMessageOnly(true)
+	defer SetMessageOnly(false)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Message", E(Op("service.Find"), E(Op("repo.Find"), NotExist, "no such user")), "no such user"},
+		{"Wrapped Error", E(Op("repo.Find"), Database, sql.ErrNoRows), sql.ErrNoRows.Error()},
+		{"Wrapped by fmt.Errorf", E(Op("service.Find"), fmt.Errorf("find: %w", E(Op("repo.Find"), "no such user"))), "no such user"},
+		{"Kind Only", E(Op("repo.Find"), NotExist), NotExist.String()},
+		{"Op Only", E(Op("repo.Find")), "repo.Find"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	SetMessageOnly(false)
+	err := E(Op("repo.Find"), NotExist, "no such user")
+	if got, want := err.Error(), "repo.Find: item_does_not_exist|: no such user"; got != want {
+		t.Errorf("Error() = %q, want %q after SetMessageOnly(false)", got, want)
+	}
+}
+
+func TestSetStrict(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	tests := []struct {
+		name      string
+		args      []interface{}
+		wantPanic bool
+	}{
+		{"no conflict", []interface{}{Op("repo.Find"), NotExist, Code("user_not_found"), Parameter("id"), "no such user"}, false},
+		{"two Kinds", []interface{}{NotExist, Exist}, true},
+		{"same Kind twice", []interface{}{NotExist, NotExist}, true},
+		{"two Codes", []interface{}{Code("a"), Code("b")}, true},
+		{"two messages", []interface{}{"first", "second"}, true},
+		{"message and error", []interface{}{"no such user", sql.ErrNoRows}, true},
+		{"error and *Error", []interface{}{sql.ErrNoRows, E(NotExist)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if got := recover() != nil; got != tt.wantPanic {
+					t.Errorf("E(%v) panicked = %t, want %t", tt.args, got, tt.wantPanic)
+				}
+			}()
+			_ = E(tt.args...)
+		})
+	}
+
+	// the Builder replaces elements instead of passing them twice to E
+	_ = Build().Kind(NotExist).Kind(Exist).Msg("first").Msg("second").Err()
+}
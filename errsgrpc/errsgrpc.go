@@ -0,0 +1,116 @@
+// Package errsgrpc converts the errors of package errs to gRPC
+// statuses, the gRPC counterpart of errs.HTTPErrorResponse. It is a
+// module of its own, so programs using package errs without gRPC do
+// not depend on it.
+package errsgrpc
+
+import (
+	"sync"
+
+	"github.com/gilcrest/errs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcCodes maps an error Kind to a gRPC status code
+var grpcCodes = map[errs.Kind]codes.Code{
+	errs.Other:            codes.Unknown,
+	errs.Invalid:          codes.InvalidArgument,
+	errs.Permission:       codes.PermissionDenied,
+	errs.IO:               codes.Unavailable,
+	errs.Exist:            codes.AlreadyExists,
+	errs.NotExist:         codes.NotFound,
+	errs.Private:          codes.PermissionDenied,
+	errs.Internal:         codes.Internal,
+	errs.BrokenLink:       codes.NotFound,
+	errs.Database:         codes.Internal,
+	errs.Validation:       codes.InvalidArgument,
+	errs.Unanticipated:    codes.Unknown,
+	errs.InvalidRequest:   codes.InvalidArgument,
+	errs.Unauthenticated:  codes.Unauthenticated,
+	errs.Unauthorized:     codes.PermissionDenied,
+	errs.TooManyRequests:  codes.ResourceExhausted,
+	errs.Timeout:          codes.DeadlineExceeded,
+	errs.MethodNotAllowed: codes.Unimplemented,
+	errs.Canceled:         codes.Canceled,
+	errs.Unavailable:      codes.Unavailable,
+	errs.Conflict:         codes.Aborted,
+}
+
+var (
+	grpcCodesMu sync.RWMutex
+	// grpcOverrides holds the overrides set through SetCodeMap
+	grpcOverrides map[errs.Kind]codes.Code
+)
+
+// SetCodeMap overrides the gRPC status code returned by Code and
+// used by Status for each Kind present in m, including Kinds
+// registered through errs.RegisterKind. Kinds not present in m keep
+// their default mapping. Each call replaces the overrides from any
+// previous call; passing a nil map restores the defaults.
+//
+// SetCodeMap is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetCodeMap(m map[errs.Kind]codes.Code) {
+	overrides := make(map[errs.Kind]codes.Code, len(m))
+	for k, v := range m {
+		overrides[k] = v
+	}
+	grpcCodesMu.Lock()
+	grpcOverrides = overrides
+	grpcCodesMu.Unlock()
+}
+
+// Code returns the gRPC status code for the Kind k. A mapping set
+// through SetCodeMap takes precedence over the default mapping.
+// Kinds found in neither, such as a Kind registered through
+// errs.RegisterKind without an override, return codes.Unknown.
+func Code(k errs.Kind) codes.Code {
+	grpcCodesMu.RLock()
+	c, ok := grpcOverrides[k]
+	grpcCodesMu.RUnlock()
+	if ok {
+		return c
+	}
+	if c, ok := grpcCodes[k]; ok {
+		return c
+	}
+	return codes.Unknown
+}
+
+// Status converts err to a gRPC status. The status code is taken from
+// the Kind of err, as returned by errs.KindOf, so errors not from
+// package errs convert to codes.Unknown. The status message is the
+// one errs.HTTPErrorResponse sends, see errs.ToServiceError: the
+// UserMessage of an error takes precedence, the messages of server
+// errors are masked, see errs.SetMaskServerErrors, and the message of
+// errors not from package errs never reaches the client. The Kind,
+// Code and Param sent are attached as an errdetails.ErrorInfo detail,
+// with the Code as the Reason and the Kind and Param as Metadata.
+//
+// If err is nil, Status returns nil, which gRPC treats as OK.
+func Status(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+
+	se, _ := errs.ToServiceError(err)
+	st := status.New(Code(errs.KindOf(err)), se.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason: se.Code,
+		Metadata: map[string]string{
+			"kind": se.Kind,
+		},
+	}
+	if se.Param != "" {
+		info.Metadata["param"] = se.Param
+	}
+	// WithDetails only fails for an OK status or a detail which
+	// cannot be marshaled, neither of which can happen here
+	if withDetails, err := st.WithDetails(info); err == nil {
+		return withDetails
+	}
+	return st
+}
@@ -0,0 +1,142 @@
+package errsgrpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gilcrest/errs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+// rateLimited is registered once for all tests, as errs.RegisterKind
+// panics on a duplicate name
+var rateLimited = errs.RegisterKind("grpc_rate_limited", http.StatusTooManyRequests)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		kind errs.Kind
+		want codes.Code
+	}{
+		{errs.Other, codes.Unknown},
+		{errs.Invalid, codes.InvalidArgument},
+		{errs.Permission, codes.PermissionDenied},
+		{errs.IO, codes.Unavailable},
+		{errs.Exist, codes.AlreadyExists},
+		{errs.NotExist, codes.NotFound},
+		{errs.Private, codes.PermissionDenied},
+		{errs.Internal, codes.Internal},
+		{errs.BrokenLink, codes.NotFound},
+		{errs.Database, codes.Internal},
+		{errs.Validation, codes.InvalidArgument},
+		{errs.Unanticipated, codes.Unknown},
+		{errs.InvalidRequest, codes.InvalidArgument},
+		{errs.Unauthenticated, codes.Unauthenticated},
+		{errs.Unauthorized, codes.PermissionDenied},
+		{errs.TooManyRequests, codes.ResourceExhausted},
+		{errs.Timeout, codes.DeadlineExceeded},
+		{errs.MethodNotAllowed, codes.Unimplemented},
+		{errs.Canceled, codes.Canceled},
+		{errs.Unavailable, codes.Unavailable},
+		{errs.Conflict, codes.Aborted},
+		{errs.Kind(200), codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			if got := Code(tt.kind); got != tt.want {
+				t.Errorf("Code() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	if st := Status(nil); st != nil {
+		t.Errorf("Status(nil) = %v, want nil", st)
+	}
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    codes.Code
+		wantMessage string
+		wantInfo    *errdetails.ErrorInfo
+	}{
+		{
+			"not from errs",
+			errors.New("dial tcp 10.0.0.1:5432: connection refused"),
+			codes.Unknown,
+			"Unexpected error - contact support",
+			&errdetails.ErrorInfo{Metadata: map[string]string{"kind": "unanticipated_error"}},
+		},
+		{
+			"nested",
+			errs.E(errs.Op("service.Find"), errs.E(errs.Op("repo.Find"), errs.NotExist, errs.Code("user_not_found"), errs.Parameter("id"), "no such user")),
+			codes.NotFound,
+			"no such user",
+			&errdetails.ErrorInfo{Reason: "user_not_found", Metadata: map[string]string{"kind": "item_does_not_exist", "param": "id"}},
+		},
+		{
+			"server error masked",
+			errs.E(errs.Database, errs.Code("db_down"), "dial tcp 10.0.0.1:5432: connection refused"),
+			codes.Internal,
+			"internal server error",
+			&errdetails.ErrorInfo{Reason: "db_down", Metadata: map[string]string{"kind": "database_error"}},
+		},
+		{
+			"UserMessage",
+			errs.E(errs.Internal, errs.UserMessage("please try again later"), "cache miss storm"),
+			codes.Internal,
+			"please try again later",
+			&errdetails.ErrorInfo{Metadata: map[string]string{"kind": "internal_error"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := Status(tt.err)
+			if st.Code() != tt.wantCode {
+				t.Errorf("Status().Code() = %v, want %v", st.Code(), tt.wantCode)
+			}
+			if st.Message() != tt.wantMessage {
+				t.Errorf("Status().Message() = %q, want %q", st.Message(), tt.wantMessage)
+			}
+			details := st.Details()
+			if len(details) != 1 {
+				t.Fatalf("Status().Details() has %d details, want 1", len(details))
+			}
+			info, ok := details[0].(*errdetails.ErrorInfo)
+			if !ok {
+				t.Fatalf("Status().Details()[0] is %T, want *errdetails.ErrorInfo", details[0])
+			}
+			if info.Reason != tt.wantInfo.Reason || len(info.Metadata) != len(tt.wantInfo.Metadata) {
+				t.Errorf("Status() ErrorInfo = %v, want %v", info, tt.wantInfo)
+			}
+			for k, v := range tt.wantInfo.Metadata {
+				if info.Metadata[k] != v {
+					t.Errorf("Status() ErrorInfo Metadata[%s] = %q, want %q", k, info.Metadata[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestSetCodeMap(t *testing.T) {
+	SetCodeMap(map[errs.Kind]codes.Code{rateLimited: codes.ResourceExhausted, errs.Database: codes.Unavailable})
+	defer SetCodeMap(nil)
+
+	if got := Code(rateLimited); got != codes.ResourceExhausted {
+		t.Errorf("Code(rateLimited) = %v, want %v", got, codes.ResourceExhausted)
+	}
+	if got := Status(errs.E(errs.Database, "connection lost")).Code(); got != codes.Unavailable {
+		t.Errorf("Status(Database).Code() = %v, want %v", got, codes.Unavailable)
+	}
+	if got := Code(errs.NotExist); got != codes.NotFound {
+		t.Errorf("Code(NotExist) = %v, want %v", got, codes.NotFound)
+	}
+
+	SetCodeMap(nil)
+	if got := Code(rateLimited); got != codes.Unknown {
+		t.Errorf("Code(rateLimited) after reset = %v, want %v", got, codes.Unknown)
+	}
+}
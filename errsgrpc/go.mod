@@ -0,0 +1,16 @@
+module github.com/gilcrest/errs/errsgrpc
+
+go 1.19
+
+require (
+	github.com/gilcrest/errs v0.0.0-20261014050206-f62db43556df
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97
+	google.golang.org/grpc v1.60.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/rs/zerolog v1.20.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
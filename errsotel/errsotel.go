@@ -0,0 +1,41 @@
+// Package errsotel records the errors of package errs on
+// OpenTelemetry spans. It is a module of its own, so programs using
+// package errs without OpenTelemetry do not depend on it.
+package errsotel
+
+import (
+	"net/http"
+
+	"github.com/gilcrest/errs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecordSpanError records err on the OpenTelemetry span: err is added
+// as an exception event, its Kind and Code as the error.kind and
+// error.code attributes, and the span status is set from the HTTP
+// Status Code. The Kind and HTTP Status Code are those
+// errs.HTTPErrorResponse sends, see errs.ToServiceError, so errors not
+// from package errs are recorded with the Unanticipated Kind. Errors
+// sent as an HTTP 5xx Status Code, such as Internal or Database
+// errors, set the status to codes.Error; client errors, such as
+// NotExist or Validation, are not failures of the traced operation
+// and leave it unset: codes.Ok would be final, so a later failure
+// of the span could not set codes.Error. Nothing is recorded if err
+// is nil.
+func RecordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	se, status := errs.ToServiceError(err)
+	attrs := []attribute.KeyValue{attribute.String("error.kind", se.Kind)}
+	if code := errs.CodeOf(err); code != "" {
+		attrs = append(attrs, attribute.String("error.code", string(code)))
+	}
+	span.SetAttributes(attrs...)
+	span.RecordError(err)
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
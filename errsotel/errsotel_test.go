@@ -0,0 +1,70 @@
+package errsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gilcrest/errs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecordSpanError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantKind   string
+		wantCode   string
+	}{
+		{"nil", nil, codes.Unset, "", ""},
+		{"internal", errs.E(errs.Internal, errs.Code("db_down"), "database unreachable"), codes.Error, "internal_error", "db_down"},
+		{"not exist", errs.E(errs.NotExist, "no such user"), codes.Unset, "item_does_not_exist", ""},
+		{"unknown", errors.New("boom"), codes.Error, "unanticipated_error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("errs")
+			_, s := tracer.Start(context.Background(), "op")
+			RecordSpanError(s, tt.err)
+			s.End()
+			spans := rec.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("len(Ended()) = %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if got := span.Status().Code; got != tt.wantStatus {
+				t.Errorf("Status().Code = %v, want %v", got, tt.wantStatus)
+			}
+			attrs := make(map[attribute.Key]string)
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value.AsString()
+			}
+			if got := attrs["error.kind"]; got != tt.wantKind {
+				t.Errorf("error.kind = %q, want %q", got, tt.wantKind)
+			}
+			if got := attrs["error.code"]; got != tt.wantCode {
+				t.Errorf("error.code = %q, want %q", got, tt.wantCode)
+			}
+			if tt.err != nil && len(span.Events()) != 1 {
+				t.Errorf("len(Events()) = %d, want 1", len(span.Events()))
+			}
+		})
+	}
+}
+
+func TestRecordSpanError_ClientThenServerError(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("errs")
+	_, s := tracer.Start(context.Background(), "op")
+	RecordSpanError(s, errs.E(errs.NotExist, "no such user"))
+	RecordSpanError(s, errs.E(errs.Database, "connection lost"))
+	s.End()
+	if got := rec.Ended()[0].Status().Code; got != codes.Error {
+		t.Errorf("Status().Code = %v, want %v", got, codes.Error)
+	}
+}
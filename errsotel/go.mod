@@ -0,0 +1,18 @@
+module github.com/gilcrest/errs/errsotel
+
+go 1.20
+
+require (
+	github.com/gilcrest/errs v0.0.0-20261014050206-f62db43556df
+	go.opentelemetry.io/otel v1.21.0
+	go.opentelemetry.io/otel/sdk v1.21.0
+	go.opentelemetry.io/otel/trace v1.21.0
+)
+
+require (
+	github.com/go-logr/logr v1.3.0 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/rs/zerolog v1.20.0 // indirect
+	go.opentelemetry.io/otel/metric v1.21.0 // indirect
+	golang.org/x/sys v0.14.0 // indirect
+)
@@ -0,0 +1,35 @@
+// Package errstest provides helpers for testing code which returns
+// the errors of package errs.
+package errstest
+
+import (
+	"testing"
+
+	"github.com/gilcrest/errs"
+)
+
+// AssertKind fails the test if the Kind of err, as reported by
+// errs.KindOf, is not want.
+func AssertKind(tb testing.TB, err error, want errs.Kind) {
+	tb.Helper()
+	if err == nil {
+		tb.Errorf("error = nil, want Kind %s", want)
+		return
+	}
+	if got := errs.KindOf(err); got != want {
+		tb.Errorf("Kind of %q = %s, want %s", err, got, want)
+	}
+}
+
+// AssertCode fails the test if the Code of err, as reported by
+// errs.CodeOf, is not want.
+func AssertCode(tb testing.TB, err error, want errs.Code) {
+	tb.Helper()
+	if err == nil {
+		tb.Errorf("error = nil, want Code %q", want)
+		return
+	}
+	if got := errs.CodeOf(err); got != want {
+		tb.Errorf("Code of %q = %q, want %q", err, got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package errstest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gilcrest/errs"
+)
+
+// recorder records the failures reported through testing.TB
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertKind(t *testing.T) {
+	err := errs.E(errs.Op("repo.Find"), errs.NotExist, errs.Code("user_not_found"), "no such user")
+	tests := []struct {
+		name     string
+		err      error
+		want     errs.Kind
+		wantFail bool
+	}{
+		{"match", err, errs.NotExist, false},
+		{"wrapped match", fmt.Errorf("find: %w", err), errs.NotExist, false},
+		{"mismatch", err, errs.Exist, true},
+		{"ValidationErrors", errs.ValidationErrors{errs.NewValidation("email", "email is required")}, errs.Validation, false},
+		{"nil", nil, errs.NotExist, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertKind(r, tt.err, tt.want)
+			if got := len(r.failures) > 0; got != tt.wantFail {
+				t.Errorf("AssertKind() failed = %t, want %t: %q", got, tt.wantFail, r.failures)
+			}
+		})
+	}
+}
+
+func TestAssertCode(t *testing.T) {
+	err := errs.E(errs.Op("repo.Find"), errs.NotExist, errs.Code("user_not_found"), "no such user")
+	tests := []struct {
+		name     string
+		err      error
+		want     errs.Code
+		wantFail bool
+	}{
+		{"match", err, "user_not_found", false},
+		{"mismatch", err, "other_code", true},
+		{"nil", nil, "user_not_found", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertCode(r, tt.err, tt.want)
+			if got := len(r.failures) > 0; got != tt.wantFail {
+				t.Errorf("AssertCode() failed = %t, want %t: %q", got, tt.wantFail, r.failures)
+			}
+		})
+	}
+}
@@ -73,7 +73,7 @@ func ExampleHTTPErrorResponse() {
 	fmt.Println(w.Body)
 	// Output:
 	//
-	// {"level":"error","error":"errors/layer4: input_validation_error] errors/layer3] errors/layer2] errors/layer1|: Actual error message","HTTPStatusCode":400,"Kind":"input_validation_error","Parameter":"testParam","Code":"0212","message":"Response Error Sent"}
+	// {"level":"warn","error":{"kind":"input_validation_error","code":"0212","param":"testParam","ops":["errors/layer4","errors/layer3","errors/layer2","errors/layer1"],"message":"Actual error message"},"Code":"0212","HTTPStatusCode":400,"Kind":"input_validation_error","Ops":["errors/layer4","errors/layer3","errors/layer2","errors/layer1"],"Parameter":"testParam","message":"Response Error Sent"}
 	// {"error":{"kind":"input_validation_error","code":"0212","param":"testParam","message":"Actual error message"}}
 }
 
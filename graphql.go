@@ -0,0 +1,58 @@
+package errs
+
+import "net/http"
+
+// GraphQLError is an error in the format of the GraphQL
+// specification, with the Kind, Code and Param of the error sent as
+// extensions, e.g.
+//
+//	{"message":"no such user","extensions":{"code":"NOT_FOUND","kind":"item_does_not_exist"}}
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// graphQLCodes are the extensions codes of the HTTP Status Codes,
+// following the conventions of common GraphQL servers
+var graphQLCodes = map[int]string{
+	http.StatusBadRequest:      "BAD_USER_INPUT",
+	http.StatusUnauthorized:    "UNAUTHENTICATED",
+	http.StatusForbidden:       "FORBIDDEN",
+	http.StatusNotFound:        "NOT_FOUND",
+	http.StatusConflict:        "CONFLICT",
+	http.StatusTooManyRequests: "TOO_MANY_REQUESTS",
+}
+
+// ToGraphQLError returns err as a GraphQLError, for GraphQL
+// resolvers to reuse the Kinds of REST handlers. The message is the
+// one HTTPErrorResponse sends, see ToServiceError. The code extension
+// is derived from the HTTP Status Code of the Kind, e.g. NOT_FOUND
+// for NotExist or BAD_USER_INPUT for Validation, other client errors
+// are BAD_REQUEST and server errors INTERNAL_SERVER_ERROR, as are
+// errors not from this package. The kind extension is the Kind and
+// the error_code and param extensions, if set, the Code and Param.
+// For a nil err, ToGraphQLError returns an empty GraphQLError.
+func ToGraphQLError(err error) GraphQLError {
+	if err == nil {
+		return GraphQLError{}
+	}
+	se, httpStatusCode := ToServiceError(err)
+	code, ok := graphQLCodes[httpStatusCode]
+	if !ok {
+		code = "INTERNAL_SERVER_ERROR"
+		if httpStatusCode < http.StatusInternalServerError {
+			code = "BAD_REQUEST"
+		}
+	}
+	ext := map[string]interface{}{
+		"code": code,
+		"kind": se.Kind,
+	}
+	if se.Code != "" {
+		ext["error_code"] = se.Code
+	}
+	if se.Param != "" {
+		ext["param"] = se.Param
+	}
+	return GraphQLError{Message: se.Message, Extensions: ext}
+}
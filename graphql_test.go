@@ -0,0 +1,58 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestToGraphQLError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"not exist",
+			E(NotExist, Code("user_not_found"), Parameter("id"), "no such user"),
+			`{"message":"no such user","extensions":{"code":"NOT_FOUND","error_code":"user_not_found","kind":"item_does_not_exist","param":"id"}}`,
+		},
+		{
+			"validation",
+			NewValidation("email", "email is required"),
+			`{"message":"email is required","extensions":{"code":"BAD_USER_INPUT","kind":"input_validation_error","param":"email"}}`,
+		},
+		{
+			"unauthenticated",
+			E(Unauthenticated, "bad token"),
+			`{"message":"bad token","extensions":{"code":"UNAUTHENTICATED","kind":"unauthenticated"}}`,
+		},
+		{
+			"method not allowed",
+			E(MethodNotAllowed, "cannot delete orders"),
+			`{"message":"cannot delete orders","extensions":{"code":"BAD_REQUEST","kind":"method_not_allowed"}}`,
+		},
+		{
+			"database",
+			E(Database, "connection refused"),
+			`{"message":"internal server error","extensions":{"code":"INTERNAL_SERVER_ERROR","kind":"database_error"}}`,
+		},
+		{
+			"unknown error",
+			errors.New("boom"),
+			`{"message":"Unexpected error - contact support","extensions":{"code":"INTERNAL_SERVER_ERROR","kind":"unanticipated_error"}}`,
+		},
+		{"nil", nil, `{"message":""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(ToGraphQLError(tt.err))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("ToGraphQLError() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package errs
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// HandlerFunc is an HTTP handler which returns an error instead of
+// writing the error response itself. Use Handler to adapt it to an
+// http.HandlerFunc.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handler returns an adapter which turns a HandlerFunc into an
+// http.HandlerFunc. Any error returned by the HandlerFunc is sent to
+// the client and logged with HTTPErrorResponseCtx, using the request
+// context. If the request context holds no language, see
+// NewContextWithLanguage, messages are translated to the language
+// returned by LanguageFromRequest. A HandlerFunc which returns nil
+// must write its own response. If the HandlerFunc already wrote the
+// response headers before returning an error, the error response is
+// not sent, as the status cannot be changed anymore; the error is
+// logged with a warning instead. No body is sent in response to a
+// HEAD request, but the Content-Type and Content-Length headers are
+// those of the body a GET request would get.
+//
+//	h := errs.Handler(logger)
+//	http.Handle("/users", h(func(w http.ResponseWriter, r *http.Request) error {
+//		u, err := findUser(r)
+//		if err != nil {
+//			return errs.E(errs.NotExist, err)
+//		}
+//		return errs.WriteJSON(w, http.StatusOK, u)
+//	}))
+func Handler(logger zerolog.Logger) func(HandlerFunc) http.HandlerFunc {
+	return HandlerWithLogger(zerologLogger(logger))
+}
+
+// HandlerWithLogger behaves like Handler, but logs through lgr, as
+// WriteError does, so any logging library can be used, e.g.
+// HandlerWithLogger(SlogLogger(logger)). If lgr is nil, nothing is
+// logged.
+func HandlerWithLogger(lgr Logger) func(HandlerFunc) http.HandlerFunc {
+	return func(fn HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(*StatusRecorder); !ok {
+				w = NewStatusRecorder(w)
+			}
+			if err := fn(w, r); err != nil {
+				ctx := r.Context()
+				if _, ok := LanguageFromContext(ctx); !ok {
+					ctx = NewContextWithLanguage(ctx, LanguageFromRequest(r))
+				}
+				resp := ctxResponse(ctx, w, lgr)
+				resp.head = r.Method == http.MethodHead
+				_, _ = resp.write(err)
+			}
+		}
+	}
+}
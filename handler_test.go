@@ -0,0 +1,118 @@
+package errs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{
+			"error",
+			func(w http.ResponseWriter, r *http.Request) error {
+				return E(NotExist, Code("0404"), "no such user")
+			},
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","code":"0404","message":"no such user"}}`,
+		},
+		{
+			"no error",
+			func(w http.ResponseWriter, r *http.Request) error {
+				return WriteJSON(w, http.StatusOK, map[string]string{"name": "gilcrest"})
+			},
+			http.StatusOK,
+			`{"name":"gilcrest"}`,
+		},
+		{
+			"headers already written",
+			func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				return E(Internal, "queue is full")
+			},
+			http.StatusAccepted,
+			``,
+		},
+		{
+			"body already written",
+			func(w http.ResponseWriter, r *http.Request) error {
+				_, _ = w.Write([]byte("partial"))
+				return E(Internal, "stream broken")
+			},
+			http.StatusOK,
+			`partial`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h := Handler(zerolog.Nop())(tt.fn)
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("Handler() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("Handler() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_HeadersWritten(t *testing.T) {
+	buf := new(bytes.Buffer)
+	h := Handler(zerolog.New(buf))(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return E(Internal, "queue is full")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	log := buf.String()
+	if !strings.Contains(log, `"level":"warn"`) || !strings.Contains(log, "headers already written") {
+		t.Errorf("Handler() log = %s, want a warning that the headers were already written", log)
+	}
+}
+
+func TestHandler_Head(t *testing.T) {
+	const body = `{"error":{"kind":"item_does_not_exist","code":"0404","message":"no such user"}}`
+	h := Handler(zerolog.Nop())(func(w http.ResponseWriter, r *http.Request) error {
+		return E(NotExist, Code("0404"), "no such user")
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/users", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Handler() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Handler() body = %s, want no body", w.Body)
+	}
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(len(body)); got != want {
+		t.Errorf("Handler() Content-Length = %s, want %s", got, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != contentTypeJSON {
+		t.Errorf("Handler() Content-Type = %q, want %q", got, contentTypeJSON)
+	}
+}
+
+func TestHandlerWithLogger(t *testing.T) {
+	lgr := &recordingLogger{}
+	h := HandlerWithLogger(lgr)(func(w http.ResponseWriter, r *http.Request) error {
+		return E(Op("user.Find"), NotExist, "no such user")
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("HandlerWithLogger() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if lgr.err == nil || lgr.fields["Kind"] != KindNotExistString {
+		t.Errorf("HandlerWithLogger() logged %v %v, want the error", lgr.err, lgr.fields)
+	}
+}
@@ -2,9 +2,14 @@ package errs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -15,12 +20,155 @@ type ErrResponse struct {
 }
 
 // ServiceError has fields for Service errors. All fields with no data will
-// be omitted
+// be omitted. Value is the rejected value of Param, see ParamValue.
+// Retryable reports whether the request is worth
+// retrying, see IsTemporary. CorrelationID is the ID passed to
+// HTTPErrorResponseWithID. Errors holds one ServiceError per error
+// when several errors are sent together, e.g. for ValidationErrors.
+// Causes holds the chain of errors wrapped by the error, from the
+// outermost to the innermost, and is only sent in debug mode, see
+// SetDebug.
 type ServiceError struct {
-	Kind    string `json:"kind,omitempty"`
-	Code    string `json:"code,omitempty"`
-	Param   string `json:"param,omitempty"`
-	Message string `json:"message,omitempty"`
+	Kind          string         `json:"kind,omitempty"`
+	Code          string         `json:"code,omitempty"`
+	Param         string         `json:"param,omitempty"`
+	Value         string         `json:"value,omitempty"`
+	Message       string         `json:"message,omitempty"`
+	Retryable     bool           `json:"retryable,omitempty"`
+	CorrelationID string         `json:"correlation_id,omitempty"`
+	Errors        []ServiceError `json:"errors,omitempty"`
+	Causes        []string       `json:"causes,omitempty"`
+}
+
+// defaultStatusCodes maps an error Kind to an HTTP Status Code
+// the zero value of Kind is Other, so if no Kind is present
+// in the error, Other is the default
+var defaultStatusCodes = map[Kind]int{
+	Unauthenticated: http.StatusUnauthorized,
+	Unauthorized:    http.StatusForbidden,
+	Permission:      http.StatusForbidden,
+	Other:           http.StatusBadRequest,
+	Invalid:         http.StatusBadRequest,
+	Exist:           http.StatusConflict,
+	NotExist:        http.StatusNotFound,
+	Private:         http.StatusBadRequest,
+	BrokenLink:      http.StatusBadRequest,
+	Validation:      http.StatusBadRequest,
+	InvalidRequest:  http.StatusBadRequest,
+	IO:              http.StatusInternalServerError,
+	Internal:        http.StatusInternalServerError,
+	Database:        http.StatusInternalServerError,
+	Unanticipated:   http.StatusInternalServerError,
+	TooManyRequests: http.StatusTooManyRequests,
+	// Timeout is usually an upstream timeout, use SetStatusCodeMap
+	// to send http.StatusRequestTimeout for client caused timeouts
+	Timeout:          http.StatusGatewayTimeout,
+	MethodNotAllowed: http.StatusMethodNotAllowed,
+	Canceled:         statusClientClosedRequest,
+	Unavailable:      http.StatusServiceUnavailable,
+	Conflict:         http.StatusConflict,
+}
+
+// kindHeaders are the HTTP headers sent with the error responses for
+// a Kind, the Headers of the error can replace them
+var kindHeaders = map[Kind]Headers{
+	// close the connection, so load balancers drain the server,
+	// e.g. during a graceful shutdown
+	Unavailable: {"Connection": "close"},
+}
+
+// statusClientClosedRequest is the non-standard HTTP Status Code
+// used by nginx when the client closed the connection before the
+// response was sent
+const statusClientClosedRequest = 499
+
+var (
+	statusCodesMu sync.RWMutex
+	// statusCodes holds the overrides set through SetStatusCodeMap
+	statusCodes map[Kind]int
+)
+
+// SetStatusCodeMap overrides the HTTP Status Code sent by
+// HTTPErrorResponse for each Kind present in m. Kinds not present
+// in m keep their default mapping. Each call replaces the overrides
+// from any previous call; passing a nil map restores the defaults.
+//
+// For example, Validation and Invalid errors are sent as
+// http.StatusBadRequest (400) by default; APIs which send
+// http.StatusUnprocessableEntity (422) for semantic validation
+// failures can opt into it with:
+//
+//	errs.SetStatusCodeMap(map[errs.Kind]int{
+//		errs.Validation: http.StatusUnprocessableEntity,
+//		errs.Invalid:    http.StatusUnprocessableEntity,
+//	})
+//
+// SetStatusCodeMap is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetStatusCodeMap(m map[Kind]int) {
+	overrides := make(map[Kind]int, len(m))
+	for k, v := range m {
+		overrides[k] = v
+	}
+	statusCodesMu.Lock()
+	statusCodes = overrides
+	statusCodesMu.Unlock()
+}
+
+// statusCode returns the HTTP Status Code for a Kind. The precedence is:
+//  1. the mapping set through SetStatusCodeMap
+//  2. the default mapping in defaultStatusCodes
+//  3. the HTTP Status Code given to RegisterKind
+//  4. http.StatusInternalServerError for any other Kind
+func statusCode(k Kind) int {
+	statusCodesMu.RLock()
+	code, ok := statusCodes[k]
+	statusCodesMu.RUnlock()
+	if ok {
+		return code
+	}
+	if code, ok := defaultStatusCodes[k]; ok {
+		return code
+	}
+	if ck, ok := lookupKind(k); ok {
+		return ck.httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// StatusCode returns the HTTP Status Code HTTPErrorResponse sends for
+// e: its Status, if set, or else the one given to RegisterCode for
+// its Code, if any, or else the one for its Kind, see
+// SetStatusCodeMap. The Status, Kind and Code are the outermost ones
+// set in the chain of e, see KindOf and CodeOf. StatusCode returns
+// http.StatusInternalServerError for a nil *Error or an unknown Kind.
+//
+// A Status only overrides the HTTP Status Code: the kind sent in the
+// response body is still the one of the Kind.
+func (e *Error) StatusCode() int {
+	if e == nil {
+		return http.StatusInternalServerError
+	}
+	if s := statusOf(e); s != 0 {
+		return int(s)
+	}
+	if rc, ok := lookupCode(CodeOf(e)); ok && rc.httpStatus != 0 {
+		return rc.httpStatus
+	}
+	return statusCode(KindOf(e))
+}
+
+// statusOf returns the first Status that is not zero in the chain of
+// errors wrapped by err, like CodeOf for the Code
+func statusOf(err error) Status {
+	var e *Error
+	for depth := 0; depth < maxDepth && errors.As(err, &e); depth++ {
+		if e.Status != 0 {
+			return e.Status
+		}
+		err = e.Err
+	}
+	return 0
 }
 
 // HTTPErrorResponse takes a writer, error and a logger, performs a
@@ -28,34 +176,406 @@ type ServiceError struct {
 // the Error interface as defined in this package), then sends the
 // Error as a response to the client. If the type does not meet the
 // Error interface as defined in this package, then a proper error
-// is still formed and sent to the client, however, the Kind and
-// Code will be Unanticipated. Logging of error is also done using
-// https://github.com/rs/zerolog
+// is still formed and sent to the client, with the Unanticipated
+// Kind, the HTTP 500 Status Code and a generic message instead of
+// the error text, see SetUnanticipatedResponse; no Code is sent
+// unless one is set through SetFallbackCode. Logging of error is
+// also done using https://github.com/rs/zerolog
+//
+// The HTTP Status Code is chosen from the error Kind, see
+// SetStatusCodeMap to change the mapping, unless the error has a
+// Status, see StatusCode.
+//
+// To disable logging, e.g. when the error has already been logged,
+// pass zerolog.Nop() or a zero value zerolog.Logger. The correct
+// status code and body are still sent.
 func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error) {
+	_, _ = HTTPErrorResponseStatus(w, logger, err)
+}
+
+// HTTPErrorResponseStatus behaves like HTTPErrorResponse, but also
+// returns the HTTP Status Code that was sent to the client and any
+// error from writing the response body to w, which is useful for
+// middleware that records metrics.
+func HTTPErrorResponseStatus(w http.ResponseWriter, logger zerolog.Logger, err error) (int, error) {
+	return WriteError(w, zerologLogger(logger), err)
+}
+
+// Result describes the response sent for an error
+type Result struct {
+	// StatusCode is the HTTP Status Code sent to the client
+	StatusCode int
+	// BodyWritten reports whether a response body was sent, there
+	// is none e.g. for Unauthenticated and Unauthorized errors
+	BodyWritten bool
+}
+
+// WriteErrorResult behaves like WriteError, but returns a Result
+// describing the response, which is useful for access log middleware
+// that needs to know whether a body was sent. Use ZerologLogger to
+// log through a zerolog.Logger.
+func WriteErrorResult(w http.ResponseWriter, lgr Logger, err error) (Result, error) {
+	r := response{w: w, lgr: lgr, encode: errorEncoder()}
+	return r.write(err)
+}
+
+// WriteError sends err as a response to the client exactly as
+// HTTPErrorResponseStatus does, but logs through lgr, so any logging
+// library can be used by providing a Logger implementation. If
+// lgr is nil, nothing is logged.
+//
+// The response body is encoded by the ErrorEncoder set through
+// SetErrorEncoder, by default ErrResponseEncoder.
+func WriteError(w http.ResponseWriter, lgr Logger, err error) (int, error) {
+	res, werr := WriteErrorResult(w, lgr, err)
+	return res.StatusCode, werr
+}
+
+// WriteProblemJSON sends err as a response to the client like
+// WriteError, but the body is an RFC 7807 application/problem+json
+// ProblemDetails object, regardless of SetErrorEncoder.
+func WriteProblemJSON(w http.ResponseWriter, lgr Logger, err error) (int, error) {
+	r := response{w: w, lgr: lgr, encode: ProblemJSONEncoder}
+	res, werr := r.write(err)
+	return res.StatusCode, werr
+}
+
+// HTTPErrorResponseWithID behaves like HTTPErrorResponse, but adds
+// the correlation ID id to the logged error and to the CorrelationID
+// of the ServiceError sent to the client, so an error seen by a
+// client can be found in the server logs.
+func HTTPErrorResponseWithID(w http.ResponseWriter, logger zerolog.Logger, err error, id string) {
+	r := response{w: w, lgr: zerologLogger(logger), encode: errorEncoder(), correlationID: id}
+	_, _ = r.write(err)
+}
+
+// response holds what is needed to send a single error response
+type response struct {
+	w      http.ResponseWriter
+	lgr    Logger
+	encode ErrorEncoder
+	// correlationID, if set, is added to the log and the body
+	correlationID string
+	// lang, if set, is the language messages are translated to
+	lang string
+	// fields are added to every log entry
+	fields map[string]interface{}
+	// head reports whether the response is to a HEAD request, which
+	// must have no body
+	head bool
+}
+
+// log logs through the Logger of the response, at the level of the
+// Kind k and HTTP Status Code status, adding the fields of the
+// response and the correlation ID, if there is one
+func (r response) log(k Kind, status int, err error, msg string, fields map[string]interface{}) {
+	r.logAt(logLevel(k, status), err, msg, fields)
+}
+
+// logAt logs like log, but at the given level
+func (r response) logAt(level Level, err error, msg string, fields map[string]interface{}) {
+	if r.lgr == nil {
+		return
+	}
+	if len(r.fields) > 0 || r.correlationID != "" {
+		all := make(map[string]interface{}, len(r.fields)+len(fields)+1)
+		for k, v := range r.fields {
+			all[k] = v
+		}
+		for k, v := range fields {
+			all[k] = v
+		}
+		if r.correlationID != "" {
+			all["CorrelationID"] = r.correlationID
+		}
+		fields = all
+	}
+	if ll, ok := r.lgr.(LevelLogger); ok {
+		ll.LogAt(level, err, msg, fields)
+		return
+	}
+	r.lgr.LogError(err, msg, fields)
+}
+
+// send encodes se as the response body and sends it to the client.
+// If se cannot be encoded, the failure is logged and fallbackBody is
+// sent instead, so the client never gets an empty body.
+func (r response) send(se ServiceError, httpStatusCode int) error {
+	se = translateServiceError(se, messageTranslator(), r.lang)
+	se = redactServiceError(se, redactor())
+	se.CorrelationID = r.correlationID
+	body, contentType, err := r.encode(se, httpStatusCode)
+	if err != nil {
+		r.log(Internal, http.StatusInternalServerError, err, "Error Response Encoding Failed", map[string]interface{}{"HTTPStatusCode": httpStatusCode})
+		body, contentType = []byte(fallbackBody), contentTypeJSON
+	}
+	if r.head {
+		// send the headers of the body, but not the body itself
+		r.w.Header().Set("Content-Type", contentType)
+		r.w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		return writeResponse(r.w, "", "", httpStatusCode)
+	}
+	return writeResponse(r.w, string(body), contentType, httpStatusCode)
+}
+
+// fallbackBody is the response body sent when the ErrorEncoder fails
+const fallbackBody = `{"error":{"kind":"unanticipated_error","message":"Unexpected error - contact support"}}`
+
+// ToServiceError returns the ServiceError and HTTP Status Code that
+// HTTPErrorResponse sends for err, without logging or writing
+// anything, so transports other than net/http, such as GraphQL
+// resolvers, WebSockets or message queues, can send the same payload
+// within their own envelope. The redactor set through SetRedactor is
+// applied. ToServiceError returns the ServiceError of Unauthenticated
+// and Unauthorized errors, although HTTPErrorResponse sends no body
+// for them. For a nil err, ToServiceError returns an empty
+// ServiceError.
+func ToServiceError(err error) (ServiceError, int) {
+	se, httpStatusCode := serviceError(err)
+	return redactServiceError(se, redactor()), httpStatusCode
+}
+
+// EncodeError returns the ServiceError and HTTP Status Code that
+// HTTPErrorResponse sends for err.
+//
+// Deprecated: EncodeError encodes nothing, use ToServiceError, which
+// it calls.
+func EncodeError(err error) (ServiceError, int) {
+	return ToServiceError(err)
+}
+
+// unanticipatedMessage is the message sent for errors not from
+// this package, which must not reach the client
+const unanticipatedMessage = "Unexpected error - contact support"
+
+var (
+	unanticipatedMu sync.RWMutex
+	// unanticipated is the ServiceError set through
+	// SetUnanticipatedResponse
+	unanticipated ServiceError
+)
 
-	// statusCode maps an error Kind to an HTTP Status Code
-	// the zero value of Kind is Other, so if no Kind is present
-	// in the error, Other is the default
-	var statusCode = map[Kind]int{
-		Unauthenticated: http.StatusUnauthorized,
-		Unauthorized:    http.StatusForbidden,
-		Permission:      http.StatusForbidden,
-		Other:           http.StatusBadRequest,
-		Invalid:         http.StatusBadRequest,
-		Exist:           http.StatusBadRequest,
-		NotExist:        http.StatusBadRequest,
-		Private:         http.StatusBadRequest,
-		BrokenLink:      http.StatusBadRequest,
-		Validation:      http.StatusBadRequest,
-		InvalidRequest:  http.StatusBadRequest,
-		IO:              http.StatusInternalServerError,
-		Internal:        http.StatusInternalServerError,
-		Database:        http.StatusInternalServerError,
-		Unanticipated:   http.StatusInternalServerError,
+// SetUnanticipatedResponse sets the ServiceError sent for errors not
+// from this package, whose own message must not reach the client, e.g.
+// to use the wording of a product or point to its support page:
+//
+//	errs.SetUnanticipatedResponse(errs.ServiceError{
+//		Message: "Something went wrong, see https://example.com/support",
+//	})
+//
+// By default, the Kind is Unanticipated and the Message is
+// "Unexpected error - contact support"; an empty Kind or Message in se
+// keeps the default, so passing a zero ServiceError restores both. An
+// empty Code is replaced by the one set through SetFallbackCode, and
+// debug mode, see SetDebug, still sends the complete error as the
+// Message. The Message is also sent for the errors not from this
+// package joined by Join and for the panics recovered by
+// RecoverHandler.
+//
+// SetUnanticipatedResponse is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetUnanticipatedResponse(se ServiceError) {
+	se.Errors = append([]ServiceError(nil), se.Errors...)
+	se.Causes = append([]string(nil), se.Causes...)
+	unanticipatedMu.Lock()
+	unanticipated = se
+	unanticipatedMu.Unlock()
+}
+
+// unanticipatedResponse returns the ServiceError sent for errors not
+// from this package, see SetUnanticipatedResponse
+func unanticipatedResponse() ServiceError {
+	unanticipatedMu.RLock()
+	se := unanticipated
+	unanticipatedMu.RUnlock()
+	if se.Kind == "" {
+		se.Kind = kindString(Unanticipated)
+	}
+	if se.Message == "" {
+		se.Message = unanticipatedMessage
+	}
+	if se.Code == "" {
+		se.Code = string(fallbackCode())
+	}
+	se.Errors = append([]ServiceError(nil), se.Errors...)
+	se.Causes = append([]string(nil), se.Causes...)
+	return se
+}
+
+var (
+	fallbackCodeMu sync.RWMutex
+	// fallback is the Code set through SetFallbackCode
+	fallback Code
+)
+
+// SetFallbackCode sets the Code sent for errors not from this
+// package, which have no Code of their own. By default no Code is
+// sent for them, so clients can tell a real Code from the fallback.
+// Passing an empty Code restores the default.
+//
+// SetFallbackCode is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetFallbackCode(code Code) {
+	fallbackCodeMu.Lock()
+	fallback = code
+	fallbackCodeMu.Unlock()
+}
+
+// fallbackCode returns the Code set through SetFallbackCode
+func fallbackCode() Code {
+	fallbackCodeMu.RLock()
+	defer fallbackCodeMu.RUnlock()
+	return fallback
+}
+
+// asError returns the outermost *Error, ValidationErrors or error
+// returned by Join wrapped by err if err is an error from another
+// package wrapping one, e.g. through fmt.Errorf and %w, so it is sent
+// with its Kind and Code instead of as an unknown error. An *Error
+// which only adds context, such as an Op, to ValidationErrors or an
+// error returned by Join is skipped, so their errors are all sent.
+// Otherwise err is returned unchanged.
+func asError(err error) error {
+	for depth := 0; depth < maxDepth; depth++ {
+		switch e := err.(type) {
+		case nil, ValidationErrors, *joinError:
+			return err
+		case *Error:
+			if e == nil || e.Kind != Other || e.Code != "" || e.UserMessage != "" || e.Status != 0 {
+				return e
+			}
+			var pe packageError
+			if !errors.As(e.Err, &pe) {
+				return e
+			}
+			if _, ok := pe.(*Error); ok {
+				return e
+			}
+			err = pe
+		default:
+			var pe packageError
+			if !errors.As(err, &pe) {
+				return err
+			}
+			err = pe
+		}
+	}
+	return err
+}
+
+// errorGroup returns the outermost ValidationErrors or error returned
+// by Join in the chain of err, looking through the *Error values
+// wrapping it, or nil if there is none
+func errorGroup(err error) packageError {
+	var pe packageError
+	for depth := 0; depth < maxDepth && errors.As(err, &pe); depth++ {
+		e, ok := pe.(*Error)
+		if !ok {
+			return pe
+		}
+		err = e.Err
+	}
+	return nil
+}
+
+// serviceError returns the ServiceError and HTTP Status Code for err
+func serviceError(err error) (ServiceError, int) {
+	switch e := asError(err).(type) {
+	case nil:
+		return ServiceError{}, statusCode(Other)
+	case *Error:
+		return newServiceError(e), e.StatusCode()
+	case ValidationErrors:
+		se := ServiceError{
+			Kind:    kindString(Validation),
+			Message: e.Error(),
+		}
+		for _, ve := range e {
+			if ve != nil {
+				se.Errors = append(se.Errors, newServiceError(ve))
+			}
+		}
+		return se, statusCode(Validation)
+	case *joinError:
+		se := ServiceError{Kind: kindString(e.kind)}
+		msgs := make([]string, 0, len(e.errs))
+		for _, je := range e.errs {
+			u := unanticipatedResponse()
+			jse := ServiceError{Kind: u.Kind, Message: u.Message}
+			// do not send the message of errors not from
+			// this package, as for a single such error
+			if ie, ok := asError(je).(packageError); ok {
+				jse, _ = serviceError(ie)
+			}
+			se.Errors = append(se.Errors, jse)
+			msgs = append(msgs, jse.Message)
+		}
+		se.Message = strings.Join(msgs, "; ")
+		return se, statusCode(e.kind)
+	default:
+		se := unanticipatedResponse()
+		if debugMode() {
+			se.Message = err.Error()
+		}
+		return se, http.StatusInternalServerError
+	}
+}
+
+// write logs err, then sends it to the client and reports the
+// response to the observer set through SetErrorObserver
+func (r response) write(err error) (Result, error) {
+	var res Result
+	var werr error
+	if rec, ok := r.w.(*StatusRecorder); ok && rec.status != 0 {
+		// the status cannot be changed anymore, sending the error
+		// would only cause a superfluous WriteHeader call
+		res = Result{StatusCode: rec.status}
+		r.logAt(WarnLevel, err, "Response Error Not Sent - headers already written", map[string]interface{}{
+			"HTTPStatusCode": rec.status,
+			"Kind":           responseKind(err).String(),
+		})
+	} else {
+		for k, v := range defaultErrorHeaders() {
+			r.w.Header()[k] = append([]string(nil), v...)
+		}
+		res, werr = r.writeErr(err)
+		if r.head {
+			res.BodyWritten = false
+		}
+	}
+	if observe := errorObserver(); observe != nil {
+		observe(responseKind(err), res.StatusCode)
+	}
+	return res, werr
+}
+
+// responseKind returns the Kind err is sent with
+func responseKind(err error) Kind {
+	switch e := asError(err).(type) {
+	case nil:
+		return Other
+	case *Error:
+		return KindOf(e)
+	case ValidationErrors:
+		return Validation
+	case *joinError:
+		return e.kind
+	default:
+		return Unanticipated
 	}
+}
+
+// writeErr logs err, then sends it to the client
+func (r response) writeErr(err error) (Result, error) {
+	w := r.w
 
 	var httpStatusCode int
 
+	// logErr is the error logged, which keeps the context added by
+	// the errors wrapping an *Error
+	logErr := err
+	err = asError(err)
+
 	if err != nil {
 		// We perform a "type switch" https://tour.golang.org/methods/16
 		// to determine the interface value type
@@ -63,14 +583,27 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 		// If the interface value is of type Error (not a typical error, but
 		// the Error interface defined above), then
 		case *Error:
-			httpStatusCode = statusCode[e.Kind]
+			// the Kind of e is the outermost one set in its chain,
+			// which need not be e itself, see KindOf
+			kind := KindOf(e)
+			httpStatusCode = e.StatusCode()
+			if e.RetryAfter > 0 {
+				setRetryAfter(w, time.Duration(e.RetryAfter))
+			}
+			// headers must be set before WriteHeader is called
+			for k, v := range kindHeaders[kind] {
+				w.Header().Set(k, v)
+			}
+			for k, v := range e.Headers {
+				w.Header().Set(k, v)
+			}
 			// We can retrieve the status here and write out a specific
 			// HTTP status code. If the error is empty, just
 			// send the HTTP Status Code as response
-			if e.isZero() {
-				logger.Error().Int("HTTP Error StatusCode", httpStatusCode).Msg("")
-				sendError(w, "", httpStatusCode)
-			} else if e.Kind == Unauthenticated {
+			if e.IsZero() {
+				r.log(kind, httpStatusCode, nil, "", map[string]interface{}{"HTTP Error StatusCode": httpStatusCode})
+				return Result{StatusCode: httpStatusCode}, writeResponse(w, "", "", httpStatusCode)
+			} else if kind == Unauthenticated {
 				// For Unauthenticated and Unauthorized errors,
 				// the response body should be empty. Use logger
 				// to log the error and then just send
@@ -80,11 +613,11 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 				// and a 403 Forbidden response should be used afterwards, when the user is
 				// authenticated but isn’t authorized to perform the requested operation on
 				// the given resource."
-				logger.Error().Int("HTTP Error StatusCode", http.StatusUnauthorized).Msg(e.Error())
-				sendError(w, "", httpStatusCode)
-			} else if e.Kind == Unauthorized {
-				logger.Error().Int("HTTP Error StatusCode", http.StatusForbidden).Msg(e.Error())
-				sendError(w, "", httpStatusCode)
+				r.log(kind, httpStatusCode, logErr, "", map[string]interface{}{"HTTP Error StatusCode": http.StatusUnauthorized})
+				return Result{StatusCode: httpStatusCode}, writeResponse(w, "", "", httpStatusCode)
+			} else if kind == Unauthorized {
+				r.log(kind, httpStatusCode, logErr, "", map[string]interface{}{"HTTP Error StatusCode": http.StatusForbidden})
+				return Result{StatusCode: httpStatusCode}, writeResponse(w, "", "", httpStatusCode)
 			} else {
 				// Make a copy
 				eCopy := *e
@@ -94,89 +627,700 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 				// StripStack function
 				fullErr := &eCopy
 				// log the full embedded error before removing the
-				// error stack
-				logger.Error().Err(fullErr).
-					Int("HTTPStatusCode", httpStatusCode).
-					Str("Kind", fullErr.Kind.String()).
-					Str("Parameter", string(fullErr.Param)).
-					Str("Code", string(fullErr.Code)).
-					Msg("Response Error Sent")
-
-				// For API response errors, don't show full recursion details,
-				// just the error message (stripstack does this)
-				er := ErrResponse{
-					Error: ServiceError{
-						Kind:    e.Kind.String(),
-						Code:    string(e.Code),
-						Param:   string(e.Param),
-						Message: stripStack(fullErr),
-					},
+				// error stack, building the log fields only if
+				// there is a logger, as this is the most common path
+				if r.lgr != nil {
+					fields := make(map[string]interface{}, len(fullErr.Meta)+5)
+					// the Meta of the error cannot replace
+					// the fields set below
+					for k, v := range fullErr.Meta {
+						fields[k] = metaField(v)
+					}
+					fields["HTTPStatusCode"] = httpStatusCode
+					fields["Kind"] = kind.String()
+					fields["Parameter"] = sanitize(string(fullErr.Param))
+					fields["Code"] = sanitize(string(CodeOf(fullErr)))
+					// log the Op chain, outermost first, to locate
+					// where the error came from
+					if ops := Ops(fullErr); len(ops) > 0 {
+						opStrs := make([]string, len(ops))
+						for i, op := range ops {
+							opStrs[i] = string(op)
+						}
+						fields["Ops"] = opStrs
+					}
+					r.log(kind, httpStatusCode, logErr, "Response Error Sent", fields)
 				}
 
-				// Marshal errResponse struct to JSON for the response body
-				errJSON, _ := json.Marshal(er)
+				se, _ := serviceError(fullErr)
+				return Result{StatusCode: httpStatusCode, BodyWritten: true}, r.send(se, httpStatusCode)
+			}
 
-				sendError(w, string(errJSON), httpStatusCode)
+		case ValidationErrors:
+			// Send every validation error at once, so the client
+			// sees all the failing parameters, not just the first
+			se, httpStatusCode := serviceError(e)
+			params := make([]string, 0, len(e))
+			// meta holds the Meta of the validation errors, by
+			// parameter, e.g. the source noted by InvalidQueryParam
+			var meta map[string]Meta
+			for _, ve := range e {
+				if ve == nil {
+					continue
+				}
+				param := sanitize(string(ve.Param))
+				params = append(params, param)
+				if len(ve.Meta) == 0 {
+					continue
+				}
+				if meta == nil {
+					meta = make(map[string]Meta)
+				}
+				if meta[param] == nil {
+					meta[param] = make(Meta, len(ve.Meta))
+				}
+				for k, v := range ve.Meta {
+					meta[param][k] = metaField(v)
+				}
+			}
+
+			fields := map[string]interface{}{
+				"HTTPStatusCode": httpStatusCode,
+				"Kind":           Validation.String(),
+				"Parameters":     params,
+			}
+			if meta != nil {
+				fields["ParameterMeta"] = meta
 			}
+			r.log(Validation, httpStatusCode, e, "Response Error Sent", fields)
+
+			return Result{StatusCode: httpStatusCode, BodyWritten: true}, r.send(se, httpStatusCode)
+
+		case *joinError:
+			// Send every joined error at once, with the status
+			// code of the most severe one
+			se, httpStatusCode := serviceError(e)
+
+			// as for a single Unauthenticated or Unauthorized
+			// error, the response body should be empty
+			if e.kind == Unauthenticated || e.kind == Unauthorized {
+				r.log(e.kind, httpStatusCode, e, "", map[string]interface{}{"HTTP Error StatusCode": httpStatusCode})
+				return Result{StatusCode: httpStatusCode}, writeResponse(w, "", "", httpStatusCode)
+			}
+
+			r.log(e.kind, httpStatusCode, e, "Response Error Sent", map[string]interface{}{
+				"HTTPStatusCode": httpStatusCode,
+				"Kind":           e.kind.String(),
+			})
+
+			return Result{StatusCode: httpStatusCode, BodyWritten: true}, r.send(se, httpStatusCode)
 
 		default:
 			// Any error types we don't specifically look out for default
 			// to serving a HTTP 500
-			cd := http.StatusInternalServerError
-			er := ErrResponse{
-				Error: ServiceError{
-					Kind:    Unanticipated.String(),
-					Code:    "Unanticipated",
-					Message: "Unexpected error - contact support",
-				},
-			}
+			se, cd := serviceError(err)
 
-			logger.Error().Msgf("Unknown Error - HTTP %d - %s", cd, err.Error())
-
-			// Marshal errResponse struct to JSON for the response body
-			errJSON, _ := json.Marshal(er)
+			if r.lgr != nil {
+				r.log(Unanticipated, cd, nil, fmt.Sprintf("Unknown Error - HTTP %d - %s", cd, sanitize(err.Error())), nil)
+			}
 
-			sendError(w, string(errJSON), cd)
+			return Result{StatusCode: cd, BodyWritten: true}, r.send(se, cd)
 		}
 	} else {
-		httpStatusCode = statusCode[0]
+		httpStatusCode = statusCode(Other)
 		// if a nil error is passed, do not write a response body,
 		// just send the HTTP Status Code
-		logger.Error().Int("HTTP Error StatusCode", httpStatusCode).Msg("nil error - no response body sent")
-		sendError(w, "", httpStatusCode)
+		r.log(Other, httpStatusCode, nil, "nil error - no response body sent", map[string]interface{}{"HTTP Error StatusCode": httpStatusCode})
+		return Result{StatusCode: httpStatusCode}, writeResponse(w, "", "", httpStatusCode)
+	}
+}
+
+var (
+	redactorMu sync.RWMutex
+	// redact is the function set through SetRedactor
+	redact func(string) string
+)
+
+// SetRedactor sets a function applied to every message and parameter
+// value sent to the client by HTTPErrorResponse and its variants,
+// before the body is encoded, e.g. to remove SQL fragments, file
+// paths or user data.
+// The logged error is not redacted, so the full detail is still
+// available server side. Passing nil restores the default, which
+// leaves messages unchanged.
+//
+// SetRedactor is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetRedactor(fn func(string) string) {
+	redactorMu.Lock()
+	redact = fn
+	redactorMu.Unlock()
+}
+
+// redactor returns the function set through SetRedactor, or nil
+func redactor() func(string) string {
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+	return redact
+}
+
+var (
+	debugMu sync.RWMutex
+	// debug is the mode set through SetDebug
+	debug bool
+)
+
+// SetDebug turns debug mode on or off. In debug mode, the complete
+// error, as returned by its Error method and including the error
+// stack, is sent to the client as the message instead of the
+// stripped message or the UserMessage, and the messages of the
+// wrapped errors are sent as the Causes of the ServiceError, which
+// speeds up debugging during local development. The message of
+// errors not from this package is sent as well. Debug mode is off
+// by default and must never be turned on in production, as it leaks
+// internal details.
+//
+// SetDebug is typically called once during program initialization,
+// but it is safe for concurrent use.
+func SetDebug(on bool) {
+	debugMu.Lock()
+	debug = on
+	debugMu.Unlock()
+}
+
+// debugMode reports whether debug mode is on, see SetDebug
+func debugMode() bool {
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	return debug
+}
+
+var (
+	defaultHeadersMu sync.RWMutex
+	// defaultHeaders holds the headers set through
+	// SetDefaultErrorHeaders
+	defaultHeaders http.Header
+)
+
+// SetDefaultErrorHeaders sets HTTP headers sent with every error
+// response of HTTPErrorResponse and its variants, e.g.
+// Cache-Control: no-store or a Content-Security-Policy, so they are
+// consistent without a separate middleware. They are set first, so
+// the headers of an error, see Headers, and those of its Kind, such
+// as Connection: close for Unavailable, replace them. Content-Type
+// and X-Content-Type-Options: nosniff are set last and cannot be
+// replaced. Passing nil restores the default, which sends no
+// additional headers.
+//
+// SetDefaultErrorHeaders is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetDefaultErrorHeaders(h http.Header) {
+	headers := make(http.Header, len(h))
+	for k, v := range h {
+		headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	defaultHeadersMu.Lock()
+	defaultHeaders = headers
+	defaultHeadersMu.Unlock()
+}
+
+// defaultErrorHeaders returns the headers set through
+// SetDefaultErrorHeaders, which must not be modified
+func defaultErrorHeaders() http.Header {
+	defaultHeadersMu.RLock()
+	defer defaultHeadersMu.RUnlock()
+	return defaultHeaders
+}
+
+var (
+	observerMu sync.RWMutex
+	// observer is the function set through SetErrorObserver
+	observer func(kind Kind, status int)
+)
+
+// SetErrorObserver sets a function called by HTTPErrorResponse and
+// its variants with the Kind and HTTP Status Code of every error
+// response sent, e.g. to increment a Prometheus counter of errors
+// by Kind and status:
+//
+//	errs.SetErrorObserver(func(kind errs.Kind, status int) {
+//		errorsTotal.WithLabelValues(kind.String(), strconv.Itoa(status)).Inc()
+//	})
+//
+// Errors not from this package are reported with the Unanticipated
+// Kind. fn is called synchronously after the response is written, so
+// it must be fast and safe for concurrent use. Passing nil restores
+// the default, which observes nothing.
+//
+// SetErrorObserver is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetErrorObserver(fn func(kind Kind, status int)) {
+	observerMu.Lock()
+	observer = fn
+	observerMu.Unlock()
+}
+
+// errorObserver returns the function set through SetErrorObserver,
+// or nil
+func errorObserver() func(kind Kind, status int) {
+	observerMu.RLock()
+	defer observerMu.RUnlock()
+	return observer
+}
+
+// redactServiceError returns a copy of se with fn applied to its
+// message and value and those of its Errors
+func redactServiceError(se ServiceError, fn func(string) string) ServiceError {
+	if fn == nil {
+		return se
+	}
+	se.Message = fn(se.Message)
+	if se.Value != "" {
+		se.Value = fn(se.Value)
+	}
+	if len(se.Errors) > 0 {
+		errs := make([]ServiceError, len(se.Errors))
+		for i, ve := range se.Errors {
+			errs[i] = redactServiceError(ve, fn)
+		}
+		se.Errors = errs
+	}
+	return se
+}
+
+// setRetryAfter sets the Retry-After header to d, rounded up to
+// whole seconds
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := int64((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
+// newServiceError builds the ServiceError sent to the client for e.
+// For API response errors, don't show full recursion details,
+// just the error message (stripstack does this), or the
+// UserMessage if there is one.
+//
+// If e wraps ValidationErrors or an error returned by Join, such as
+// E(Validation, Op("user.Create"), verrs), each of their errors is
+// still sent in Errors, so the client sees all of them, and the
+// message is the one sent for them.
+func newServiceError(e *Error) ServiceError {
+	kind := KindOf(e)
+	se := ServiceError{
+		Kind:      kindString(kind),
+		Code:      string(CodeOf(e)),
+		Param:     string(e.Param),
+		Value:     string(e.ParamValue),
+		Message:   string(e.UserMessage),
+		Retryable: IsTemporary(e),
+	}
+	var gse ServiceError
+	group := errorGroup(e.Err)
+	if group != nil {
+		gse, _ = serviceError(group)
+		se.Errors = gse.Errors
+	}
+	if debugMode() {
+		se.Message = e.Error()
+		se.Causes = causes(e)
+	} else if se.Message == "" {
+		if msg, ok := kindMessage(kind); ok {
+			se.Message = msg
+		} else if maskServerErrors() && e.StatusCode() >= http.StatusInternalServerError {
+			se.Message = serverErrorMessage
+		} else if group != nil {
+			se.Message = gse.Message
+		} else {
+			se.Message = e.Message()
+		}
+	}
+	if se.Message == "" {
+		// never send an empty message, nor the operations of the
+		// error, for an error without a message, e.g. E(NotExist)
+		se.Message = kindText(kind)
+	}
+	return se
+}
+
+// kindText returns the message derived from the Kind k for errors
+// without a message, e.g. "item does not exist" for NotExist
+func kindText(k Kind) string {
+	return strings.Replace(k.String(), "_", " ", -1)
+}
+
+// serverErrorMessage is the message sent for *Error values sent with
+// an HTTP 5xx Status Code, see SetMaskServerErrors
+const serverErrorMessage = "internal server error"
+
+var (
+	maskMu sync.RWMutex
+	// unmasked is the negation of the mode set through
+	// SetMaskServerErrors, so the zero value masks
+	unmasked bool
+)
+
+// SetMaskServerErrors turns the masking of server error messages on
+// or off. When on, the default, the message of an *Error sent with an
+// HTTP 5xx Status Code, such as an Internal or Database error, is
+// replaced by "internal server error", so internal details never
+// reach the client; the complete error is still logged. The
+// UserMessage of an error and the messages set through
+// SetKindMessages are meant for the client and are still sent, as is
+// the complete error in debug mode, see SetDebug.
+//
+// SetMaskServerErrors is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetMaskServerErrors(on bool) {
+	maskMu.Lock()
+	unmasked = !on
+	maskMu.Unlock()
+}
+
+// maskServerErrors reports whether server error messages are
+// masked, see SetMaskServerErrors
+func maskServerErrors() bool {
+	maskMu.RLock()
+	defer maskMu.RUnlock()
+	return !unmasked
+}
+
+var (
+	snakeCaseMu sync.RWMutex
+	// snakeCaseKinds is the mode set through SetSnakeCaseKinds
+	snakeCaseKinds bool
+)
+
+// SetSnakeCaseKinds turns the sending of Kinds in snake_case on or
+// off. When on, the kind of every ServiceError sent by
+// HTTPErrorResponse, and returned by ToServiceError, is the
+// SnakeString of its Kind instead of its String, e.g. "io_error"
+// instead of "I/O_error" for IO and "rate_limited" for a Kind
+// registered as "RateLimited". KindFromString recognizes both. It is
+// off by default. The Kind of an unknown error set through
+// SetUnanticipatedResponse is sent as is.
+//
+// SetSnakeCaseKinds is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetSnakeCaseKinds(on bool) {
+	snakeCaseMu.Lock()
+	snakeCaseKinds = on
+	snakeCaseMu.Unlock()
+}
+
+// kindString returns the string k is sent as, see SetSnakeCaseKinds
+func kindString(k Kind) string {
+	snakeCaseMu.RLock()
+	snake := snakeCaseKinds
+	snakeCaseMu.RUnlock()
+	if snake {
+		return k.SnakeString()
 	}
+	return k.String()
+}
+
+var (
+	kindMessagesMu sync.RWMutex
+	// kindMessages holds the messages set through SetKindMessages
+	kindMessages map[Kind]string
+)
+
+// SetKindMessages sets the message sent to the client for the errors
+// of each Kind present in m, instead of their own message, e.g. to
+// always send the same generic wording for Unauthorized errors so no
+// internal detail leaks. The UserMessage of an error still takes
+// precedence and debug mode, see SetDebug, sends the complete error
+// regardless. Each call replaces the messages from any previous call;
+// passing a nil map restores the default, which sends the message of
+// every error.
+//
+// SetKindMessages is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetKindMessages(m map[Kind]string) {
+	msgs := make(map[Kind]string, len(m))
+	for k, v := range m {
+		msgs[k] = v
+	}
+	kindMessagesMu.Lock()
+	kindMessages = msgs
+	kindMessagesMu.Unlock()
+}
+
+// kindMessage returns the message set through SetKindMessages for
+// the errors of Kind k, if any
+func kindMessage(k Kind) (string, bool) {
+	kindMessagesMu.RLock()
+	defer kindMessagesMu.RUnlock()
+	msg, ok := kindMessages[k]
+	return msg, ok
+}
+
+// causes returns the messages of the errors wrapped by err, from
+// the outermost to the innermost
+func causes(err error) []string {
+	var msgs []string
+	for err = errors.Unwrap(err); err != nil && len(msgs) < maxDepth; err = errors.Unwrap(err) {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+// Content types of the response bodies written by this package.
+// An ErrorEncoder set through SetErrorEncoder chooses its own.
+const (
+	contentTypeJSON        = "application/json; charset=utf-8"
+	contentTypeProblemJSON = "application/problem+json; charset=utf-8"
+)
+
+// ErrorEncoder encodes the ServiceError sent to the client,
+// returning the response body and its content type. It is also
+// given the HTTP Status Code of the response, as some formats,
+// such as RFC 7807 problem details, include it in the body.
+// If it returns an error, the error is logged and a minimal
+// JSON body is sent instead.
+type ErrorEncoder func(se ServiceError, httpStatusCode int) ([]byte, string, error)
+
+var (
+	encoderMu sync.RWMutex
+	// encoder is the ErrorEncoder set through SetErrorEncoder
+	encoder ErrorEncoder
+)
+
+// SetErrorEncoder sets the ErrorEncoder used by HTTPErrorResponse
+// and WriteError, which controls the wire format of error response
+// bodies, e.g. to send the ServiceError fields at the top level or
+// within an "errors" array. Passing nil restores the default,
+// ErrResponseEncoder.
+//
+// SetErrorEncoder is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetErrorEncoder(enc ErrorEncoder) {
+	encoderMu.Lock()
+	encoder = enc
+	encoderMu.Unlock()
+}
+
+// errorEncoder returns the ErrorEncoder to use for error responses
+func errorEncoder() ErrorEncoder {
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	if encoder == nil {
+		return ErrResponseEncoder
+	}
+	return encoder
+}
+
+// ErrResponseEncoder is the default ErrorEncoder, it encodes se
+// as JSON within an ErrResponse, i.e. under an "error" key.
+func ErrResponseEncoder(se ServiceError, _ int) ([]byte, string, error) {
+	// Marshal errResponse struct to JSON for the response body
+	errJSON, err := json.Marshal(ErrResponse{Error: se})
+	return errJSON, contentTypeJSON, err
+}
+
+// ProblemDetails is an RFC 7807 problem details object, used as the
+// Response Body by WriteProblemJSON. The Kind is sent as the Title,
+// the HTTP Status Code as the Status and the error message as the
+// Detail. Code, Param, Value, Errors and Causes are sent as extension
+// members.
+type ProblemDetails struct {
+	Type          string         `json:"type,omitempty"`
+	Title         string         `json:"title,omitempty"`
+	Status        int            `json:"status,omitempty"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code,omitempty"`
+	Param         string         `json:"param,omitempty"`
+	Value         string         `json:"value,omitempty"`
+	CorrelationID string         `json:"correlation_id,omitempty"`
+	Errors        []ServiceError `json:"errors,omitempty"`
+	Causes        []string       `json:"causes,omitempty"`
+}
+
+// ProblemJSONEncoder is an ErrorEncoder which encodes se as an
+// RFC 7807 ProblemDetails object.
+func ProblemJSONEncoder(se ServiceError, httpStatusCode int) ([]byte, string, error) {
+	pd := ProblemDetails{
+		Title:         se.Kind,
+		Status:        httpStatusCode,
+		Detail:        se.Message,
+		Code:          se.Code,
+		Param:         se.Param,
+		Value:         se.Value,
+		CorrelationID: se.CorrelationID,
+		Errors:        se.Errors,
+		Causes:        se.Causes,
+	}
+	errJSON, err := json.Marshal(pd)
+	return errJSON, contentTypeProblemJSON, err
+}
+
+// FieldNameEncoder returns an ErrorEncoder which encodes se like
+// ErrResponseEncoder, but with the JSON field names replaced by those
+// in names, which maps the default names, such as "kind" or
+// "message", to the names to send instead, e.g. to match an existing
+// contract with clients:
+//
+//	errs.SetErrorEncoder(errs.FieldNameEncoder(map[string]string{
+//		"kind":    "errorType",
+//		"message": "detail",
+//	}))
+//
+// The "error" key the ServiceError is sent under can be renamed as
+// well. Names are also replaced in the Errors of se. Fields not in
+// names keep their default name and the fields are sent sorted by
+// name.
+func FieldNameEncoder(names map[string]string) ErrorEncoder {
+	m := make(map[string]string, len(names))
+	for k, v := range names {
+		m[k] = v
+	}
+	return func(se ServiceError, _ int) ([]byte, string, error) {
+		fields, err := renameFields(se, m)
+		if err != nil {
+			return nil, "", err
+		}
+		errJSON, err := json.Marshal(map[string]interface{}{fieldName(m, "error"): fields})
+		return errJSON, contentTypeJSON, err
+	}
+}
+
+// renameFields returns the JSON fields of se, and those of its
+// Errors, by name, with the names replaced by those in names
+func renameFields(se ServiceError, names map[string]string) (map[string]interface{}, error) {
+	b, err := json.Marshal(se)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		fields[fieldName(names, k)] = v
+	}
+	if len(se.Errors) > 0 {
+		errs := make([]interface{}, len(se.Errors))
+		for i, e := range se.Errors {
+			if errs[i], err = renameFields(e, names); err != nil {
+				return nil, err
+			}
+		}
+		fields[fieldName(names, "errors")] = errs
+	}
+	return fields, nil
+}
+
+// fieldName returns the name in names replacing the default name,
+// or name itself if it is not replaced
+func fieldName(names map[string]string, name string) string {
+	if n, ok := names[name]; ok && n != "" {
+		return n
+	}
+	return name
+}
+
+// WriteJSON sends v encoded as JSON with the HTTP Status Code
+// httpStatusCode, setting the same headers as the error responses
+// of this package, so success and error responses are consistent.
+// If v cannot be encoded, nothing is written and the error is
+// returned, otherwise any error from writing the response body is.
+func WriteJSON(w http.ResponseWriter, httpStatusCode int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeResponse(w, string(b), contentTypeJSON, httpStatusCode)
 }
 
 // Taken from standard library, but changed to send application/json as header
 // Error replies to the request with the specified error message and HTTP code.
 // It does not otherwise end the request; the caller should ensure no further
 // writes are done to w.
-// The error message should be json.
-func sendError(w http.ResponseWriter, errStr string, httpStatusCode int) {
+// The error message should be of the given content type, usually json.
+// Any error from writing the response body is returned.
+func writeResponse(w http.ResponseWriter, errStr, contentType string, httpStatusCode int) error {
 	if errStr != "" {
-		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", contentType)
 	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	// TODO - refactor this package to allow for WWW-Authenticate header on 401/403
-	//if httpStatusCode == 401 || httpStatusCode == 403 {
-	//	br := fmt.Sprintf(`Bearer realm="%s"`, realm)
-	//	w.Header().Set("WWW-Authenticate", br)
-	//}
 	w.WriteHeader(httpStatusCode)
 	// Only write response body if there is an error string populated
 	if errStr != "" {
-		_, _ = fmt.Fprintln(w, errStr)
+		_, err := w.Write([]byte(errStr))
+		return err
 	}
+	return nil
 }
 
 // stripStack takes an Error type (Error defined in this module) and
-// removes the leading stack information
+// removes the leading stack information. If there is no stack
+// information, the error string is returned unchanged. When errors
+// from other packages wrap nested *Error values, each level adds
+// its own stack information, so everything up to the last "|:"
+// separator is removed, leaving the innermost message.
+//
+// The stack information of the *Error values directly nested in e
+// is skipped without building it, so only the message of the
+// innermost error is formatted, after the messages added by Wrapf.
 func stripStack(e *Error) string {
-	// get error string
-	errStr := e.Error()
-	// get position where |: character lands in string
-	idx := strings.Index(errStr, "|:")
-	// substring from after the |: character
-	s := errStr[idx+3:]
-	return s
+	var msgs []string
+	inner := e
+	for depth := 0; depth < maxDepth; depth++ {
+		if inner.msg != "" {
+			msgs = append(msgs, inner.msg)
+		}
+		next, ok := inner.Err.(*Error)
+		if !ok || next.IsZero() {
+			break
+		}
+		inner = next
+	}
+	if _, ok := inner.Err.(*Error); ok || inner.Err == nil {
+		// there is no message to skip to
+		if len(msgs) > 0 {
+			return strings.Join(msgs, ": ")
+		}
+		return cutStack(e.Error())
+	}
+	return strings.Join(append(msgs, cutStack(inner.Err.Error())), ": ")
+}
+
+// cutStack returns s after its last "|:" separator, or s unchanged
+// if there is none
+func cutStack(s string) string {
+	// get position where the last |: character lands in string
+	idx := strings.LastIndex(s, "|:")
+	if idx == -1 {
+		return s
+	}
+	// substring from after the "|: " separator, if the message
+	// following it is empty, so is the result
+	if idx+3 > len(s) {
+		return ""
+	}
+	return s[idx+3:]
+}
+
+// WriteStripped writes the message of err to w, as it is sent to the
+// client by HTTPErrorResponse, see ToServiceError, and returns the
+// number of bytes written: the UserMessage of err if there is one,
+// otherwise the message of the innermost error, without the error
+// stack details, or the message derived from the Kind for an error
+// without a message, with server errors masked, see
+// SetMaskServerErrors. Unlike formatting the complete error and
+// stripping it, only the innermost message of nested *Error values is
+// formatted, which saves memory for very large errors, e.g. when
+// streaming error bodies. The message of errors not from this package
+// is written unchanged, unless they wrap an error of this package,
+// and nothing is written for a nil err.
+func WriteStripped(w io.Writer, err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	if _, ok := asError(err).(packageError); !ok {
+		return io.WriteString(w, err.Error())
+	}
+	se, _ := ToServiceError(err)
+	return io.WriteString(w, se.Message)
 }
@@ -0,0 +1,1206 @@
+package errs
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetStatusCodeMap(t *testing.T) {
+	SetStatusCodeMap(map[Kind]int{NotExist: http.StatusGone})
+	defer SetStatusCodeMap(nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Override", E(NotExist, "no such user"), http.StatusGone},
+		{"Default", E(Database, "connection refused"), http.StatusInternalServerError},
+		{"Other", E("no kind"), http.StatusBadRequest},
+		{"Unknown Kind", E(Kind(200), "unknown kind"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != tt.want {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	SetStatusCodeMap(nil)
+	if got := statusCode(NotExist); got != http.StatusNotFound {
+		t.Errorf("statusCode(NotExist) after reset = %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestHTTPErrorResponse_DefaultStatusCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		want      int
+		wantEmpty bool
+	}{
+		{"NotExist", E(NotExist, "no such user"), http.StatusNotFound, false},
+		{"Exist", E(Exist, "user already exists"), http.StatusConflict, false},
+		{"Conflict", E(Conflict, "order was modified since version 3"), http.StatusConflict, false},
+		{"Unavailable", E(Unavailable, "shutting down"), http.StatusServiceUnavailable, false},
+		{"Validation", E(Validation, "bad input"), http.StatusBadRequest, false},
+		{"Invalid", E(Invalid, "bad operation"), http.StatusBadRequest, false},
+		{"Unauthenticated", E(Unauthenticated, "bad token"), http.StatusUnauthorized, true},
+		{"Unauthorized", E(Unauthorized, "not allowed"), http.StatusForbidden, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != tt.want {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.want)
+			}
+			if empty := w.Body.Len() == 0; empty != tt.wantEmpty {
+				t.Errorf("HTTPErrorResponse() empty body = %t, want %t", empty, tt.wantEmpty)
+			}
+		})
+	}
+}
+
+// failingWriter is an http.ResponseWriter whose Write always fails
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestHTTPErrorResponseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    int
+		wantErr bool
+	}{
+		{"nil error", nil, http.StatusBadRequest, false},
+		{"Error", E(NotExist, "no such user"), http.StatusNotFound, false},
+		{"Empty Body", E(Unauthenticated, "bad token"), http.StatusUnauthorized, false},
+		{"Non-Error", errors.New("unexpected"), http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			got, err := HTTPErrorResponseStatus(w, zerolog.Nop(), tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPErrorResponseStatus() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want || w.Code != tt.want {
+				t.Errorf("HTTPErrorResponseStatus() = %d, recorded %d, want %d", got, w.Code, tt.want)
+			}
+		})
+	}
+
+	t.Run("Write Failure", func(t *testing.T) {
+		w := failingWriter{httptest.NewRecorder()}
+		got, err := HTTPErrorResponseStatus(w, zerolog.Nop(), E(NotExist, "no such user"))
+		if err == nil {
+			t.Error("HTTPErrorResponseStatus() error = nil, want write error")
+		}
+		if got != http.StatusNotFound {
+			t.Errorf("HTTPErrorResponseStatus() = %d, want %d", got, http.StatusNotFound)
+		}
+	})
+}
+
+func TestHTTPErrorResponse_ContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, "no such user"))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want %q", ct, "application/json; charset=utf-8")
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want %q", got, "nosniff")
+	}
+}
+
+func TestHTTPErrorResponse_NoLogging(t *testing.T) {
+	err := E(NotExist, Code("user_not_found"), "no such user")
+	wantBody := `{"error":{"kind":"item_does_not_exist","code":"user_not_found","message":"no such user"}}`
+
+	t.Run("Zero Value zerolog.Logger", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		var logger zerolog.Logger
+		HTTPErrorResponse(w, logger, err)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+		if w.Body.String() != wantBody {
+			t.Errorf("HTTPErrorResponse() body = %q, want %q", w.Body.String(), wantBody)
+		}
+	})
+
+	t.Run("nil Logger", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if _, err := WriteError(w, nil, err); err != nil {
+			t.Fatalf("WriteError() error = %v", err)
+		}
+		if w.Code != http.StatusNotFound {
+			t.Errorf("WriteError() status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+		if w.Body.String() != wantBody {
+			t.Errorf("WriteError() body = %q, want %q", w.Body.String(), wantBody)
+		}
+	})
+}
+
+func TestWriteStripped(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Error", E(Op("service"), E(Op("repo"), NotExist, "user not found")), "user not found"},
+		{"Wrapped", E(Op("service"), fmt.Errorf("wrap: %w", E(Op("repo"), NotExist, "user not found"))), "user not found"},
+		{"Wrapped by fmt", fmt.Errorf("wrap: %w", E(Op("repo"), NotExist, "user not found")), "user not found"},
+		{"No message", E(Op("service"), E(Op("repo"), NotExist)), "item does not exist"},
+		{"Server error", E(Op("repo"), Database, "pq: password authentication failed"), serverErrorMessage},
+		{"UserMessage", E(Op("repo"), Database, UserMessage("please try again later"), "pq: connection refused"), "please try again later"},
+		{"ValidationErrors", ValidationErrors{NewValidation("email", "email is invalid"), NewValidation("name", "name is required")}, "email is invalid; name is required"},
+		{"Unknown", errors.New("boom"), "boom"},
+		{"Nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			n, err := WriteStripped(&b, tt.err)
+			if err != nil {
+				t.Fatalf("WriteStripped() error = %v", err)
+			}
+			if b.String() != tt.want || n != len(tt.want) {
+				t.Errorf("WriteStripped() = %d, %q, want %d, %q", n, b.String(), len(tt.want), tt.want)
+			}
+		})
+	}
+}
+
+func TestStripStack(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{"Stack", E(Op("op"), Validation, "bad input").(*Error), "bad input"},
+		{"No Op", E(Validation, "bad input").(*Error), "bad input"},
+		{"No Marker", E(Op("op"), NotExist).(*Error), "op: item_does_not_exist"},
+		{"Empty Message", E(NotExist, "").(*Error), ""},
+		{"Marker at End", E("short|:").(*Error), ""},
+		{"No Error", E(Parameter("param")).(*Error), "no error"},
+		{"Three Levels", E(Op("layer3"), fmt.Errorf("layer3 wrap: %w",
+			E(Op("layer2"), fmt.Errorf("layer2 wrap: %w",
+				E(Op("layer1"), NotExist, "user not found"))))).(*Error), "user not found"},
+		{"Nested", E(Op("service"), E(Op("repo"), NotExist, "user not found")).(*Error), "user not found"},
+		{"Nested No Message", E(Op("service"), E(Op("repo"), NotExist)).(*Error), "service: item_does_not_exist] repo"},
+		{"Nested Zero", E(Op("service"), NotExist, &Error{}).(*Error), "service: item_does_not_exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripStack(tt.err); got != tt.want {
+				t.Errorf("stripStack(%q) = %q, want %q", tt.err.Error(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteProblemJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := E(Op("repo.Find"), NotExist, Code("user_not_found"), Parameter("id"), "no such user")
+	got, werr := WriteProblemJSON(w, nil, err)
+	if werr != nil {
+		t.Fatalf("WriteProblemJSON() error = %v", werr)
+	}
+	if got != http.StatusNotFound || w.Code != http.StatusNotFound {
+		t.Errorf("WriteProblemJSON() status = %d, recorded %d, want %d", got, w.Code, http.StatusNotFound)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want %q", ct, "application/problem+json; charset=utf-8")
+	}
+	want := `{"title":"item_does_not_exist","status":404,"detail":"no such user","code":"user_not_found","param":"id"}`
+	if w.Body.String() != want {
+		t.Errorf("WriteProblemJSON() body = %s, want %s", w.Body, want)
+	}
+
+	// errors without a body are still sent without one
+	w = httptest.NewRecorder()
+	if _, err := WriteProblemJSON(w, nil, E(Unauthenticated, "bad token")); err != nil {
+		t.Fatalf("WriteProblemJSON() error = %v", err)
+	}
+	if w.Code != http.StatusUnauthorized || w.Body.Len() != 0 {
+		t.Errorf("WriteProblemJSON() = %d %q, want %d and no body", w.Code, w.Body, http.StatusUnauthorized)
+	}
+}
+
+func TestSetErrorEncoder(t *testing.T) {
+	// send the ServiceError fields at the top level
+	SetErrorEncoder(func(se ServiceError, _ int) ([]byte, string, error) {
+		b, err := json.Marshal(se)
+		return b, "application/vnd.example+json", err
+	})
+	defer SetErrorEncoder(nil)
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, Code("user_not_found"), "no such user"))
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.example+json" {
+		t.Errorf("Content-Type = %q, want %q", ct, "application/vnd.example+json")
+	}
+	want := `{"kind":"item_does_not_exist","code":"user_not_found","message":"no such user"}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	SetErrorEncoder(nil)
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, Code("user_not_found"), "no such user"))
+	want = `{"error":{"kind":"item_does_not_exist","code":"user_not_found","message":"no such user"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body after reset = %s, want %s", w.Body, want)
+	}
+}
+
+func TestHTTPErrorResponse_Retryable(t *testing.T) {
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(Op("repo.Find"), Database, "connection lost"))
+	want := `{"error":{"kind":"database_error","message":"internal server error","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
+
+func TestHTTPErrorResponseWithID(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := zerolog.New(buf)
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponseWithID(w, logger, E(NotExist, "no such user"), "req-123")
+
+	want := `{"error":{"kind":"item_does_not_exist","message":"no such user","correlation_id":"req-123"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponseWithID() body = %s, want %s", w.Body, want)
+	}
+	if !strings.Contains(buf.String(), `"CorrelationID":"req-123"`) {
+		t.Errorf("HTTPErrorResponseWithID() log = %s, want CorrelationID", buf)
+	}
+
+	// errors without a body still log the ID
+	buf.Reset()
+	w = httptest.NewRecorder()
+	HTTPErrorResponseWithID(w, logger, E(Unauthenticated, "bad token"), "req-456")
+	if w.Body.Len() != 0 {
+		t.Errorf("HTTPErrorResponseWithID() body = %s, want none", w.Body)
+	}
+	if !strings.Contains(buf.String(), `"CorrelationID":"req-456"`) {
+		t.Errorf("HTTPErrorResponseWithID() log = %s, want CorrelationID", buf)
+	}
+
+	// the unknown error branch also logs the ID
+	buf.Reset()
+	w = httptest.NewRecorder()
+	HTTPErrorResponseWithID(w, logger, errors.New("unexpected"), "req-789")
+	if !strings.Contains(w.Body.String(), `"correlation_id":"req-789"`) || !strings.Contains(buf.String(), `"CorrelationID":"req-789"`) {
+		t.Errorf("HTTPErrorResponseWithID() body = %s, log = %s, want the ID in both", w.Body, buf)
+	}
+}
+
+func TestHTTPErrorResponse_TooManyRequests(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantRetryAfter string
+	}{
+		{"No RetryAfter", E(TooManyRequests, "slow down"), ""},
+		{"RetryAfter", E(TooManyRequests, RetryAfter(30*time.Second), "slow down"), "30"},
+		{"Rounded Up", E(TooManyRequests, RetryAfter(1500*time.Millisecond), "slow down"), "2"},
+		{"Nested", E(Op("handler"), E(Op("limiter"), TooManyRequests, RetryAfter(time.Minute), "slow down")), "60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if !strings.Contains(w.Body.String(), `"kind":"too_many_requests"`) {
+				t.Errorf("HTTPErrorResponse() body = %s, want kind too_many_requests", w.Body)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_Timeout(t *testing.T) {
+	err := E(Op("client.Get"), Timeout, "upstream timed out")
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	want := `{"error":{"kind":"timeout","message":"internal server error","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	// client caused timeouts can be sent as 408 instead
+	SetStatusCodeMap(map[Kind]int{Timeout: http.StatusRequestTimeout})
+	defer SetStatusCodeMap(nil)
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	if w.Code != http.StatusRequestTimeout {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusRequestTimeout)
+	}
+}
+
+func TestSetStatusCodeMap_UnprocessableEntity(t *testing.T) {
+	SetStatusCodeMap(map[Kind]int{
+		Validation: http.StatusUnprocessableEntity,
+		Invalid:    http.StatusUnprocessableEntity,
+	})
+	defer SetStatusCodeMap(nil)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Validation", NewValidation("email", "email is required")},
+		{"Invalid", E(Invalid, "cannot delete an active user")},
+		{"ValidationErrors", ValidationErrors{NewValidation("email", "email is required")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_MethodNotAllowed(t *testing.T) {
+	err := E(Op("handler.Users"), MethodNotAllowed, Headers{"Allow": "GET, POST"}, "method DELETE not allowed")
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, POST" {
+		t.Errorf("Allow = %q, want %q", got, "GET, POST")
+	}
+	want := `{"error":{"kind":"method_not_allowed","message":"method DELETE not allowed"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
+
+func TestSetRedactor(t *testing.T) {
+	digits := regexp.MustCompile(`[0-9]+`)
+	SetRedactor(func(msg string) string {
+		return digits.ReplaceAllString(msg, "***")
+	})
+	defer SetRedactor(nil)
+
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.New(buf), E(NotExist, "no user with card 4111111111111111"))
+
+	want := `{"error":{"kind":"item_does_not_exist","message":"no user with card ***"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+	if !strings.Contains(buf.String(), "4111111111111111") {
+		t.Errorf("HTTPErrorResponse() log = %s, want the unredacted message", buf)
+	}
+
+	// every message of several errors is redacted
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), ValidationErrors{NewValidation("card", "card 4111111111111111 is expired")})
+	if strings.Contains(w.Body.String(), "4111111111111111") {
+		t.Errorf("HTTPErrorResponse() body = %s, want redacted messages", w.Body)
+	}
+}
+
+func TestSetErrorObserver(t *testing.T) {
+	type observation struct {
+		kind   Kind
+		status int
+	}
+	var got []observation
+	SetErrorObserver(func(kind Kind, status int) {
+		got = append(got, observation{kind, status})
+	})
+	defer SetErrorObserver(nil)
+
+	for _, err := range []error{
+		E(NotExist, "no such user"),
+		ValidationErrors{NewValidation("email", "email is required")},
+		E(Unauthenticated, "bad token"),
+		errors.New("boom"),
+		nil,
+	} {
+		HTTPErrorResponse(httptest.NewRecorder(), zerolog.Nop(), err)
+	}
+
+	want := []observation{
+		{NotExist, http.StatusNotFound},
+		{Validation, http.StatusBadRequest},
+		{Unauthenticated, http.StatusUnauthorized},
+		{Unanticipated, http.StatusInternalServerError},
+		{Other, http.StatusBadRequest},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("observed %v, want %v", got, want)
+	}
+}
+
+func TestHTTPErrorResponse_WrappedByFmt(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			"not exist",
+			fmt.Errorf("find user: %w", E(Op("repo.Find"), NotExist, Code("0404"), "no such user")),
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","code":"0404","message":"no such user"}}`,
+		},
+		{
+			"twice",
+			fmt.Errorf("handle: %w", fmt.Errorf("find user: %w", E(Validation, Parameter("id"), "id is required"))),
+			http.StatusBadRequest,
+			`{"error":{"kind":"input_validation_error","param":"id","message":"id is required"}}`,
+		},
+		{
+			"unauthenticated",
+			fmt.Errorf("check token: %w", E(Unauthenticated, "token expired")),
+			http.StatusUnauthorized,
+			``,
+		},
+		{
+			"unknown",
+			fmt.Errorf("find user: %w", errors.New("boom")),
+			http.StatusInternalServerError,
+			`{"error":{"kind":"unanticipated_error","message":"Unexpected error - contact support"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.New(buf), tt.err)
+			if w.Code != tt.wantCode {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.wantBody)
+			}
+			// the context added by the wrapping errors is logged
+			if !strings.Contains(buf.String(), strings.SplitN(tt.err.Error(), ":", 2)[0]) {
+				t.Errorf("HTTPErrorResponse() log = %s, want the complete error", buf)
+			}
+		})
+	}
+
+	se, code := ToServiceError(fmt.Errorf("find user: %w", E(NotExist, "no such user")))
+	if se.Kind != KindNotExistString || code != http.StatusNotFound {
+		t.Errorf("ToServiceError() = %q, %d, want %q, %d", se.Kind, code, KindNotExistString, http.StatusNotFound)
+	}
+}
+
+func TestHTTPErrorResponse_PlainErrorWrapped(t *testing.T) {
+	plainErr := errors.New("dial tcp 10.0.0.5:5432: connection refused")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"WithStack", WithStack(plainErr)},
+		{"Errorf", Errorf("query users: %w", plainErr)},
+		{"Wrap", Wrap(plainErr, Op("repo.Find"))},
+		{"Wrapf", Wrapf(plainErr, Op("repo.Find"), "finding user %d", 42)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			want := `{"error":{"kind":"unanticipated_error","message":"internal server error"}}`
+			if w.Body.String() != want {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+			}
+		})
+	}
+}
+
+func TestFieldNameEncoder(t *testing.T) {
+	SetErrorEncoder(FieldNameEncoder(map[string]string{
+		"error":   "fault",
+		"kind":    "errorType",
+		"message": "detail",
+	}))
+	defer SetErrorEncoder(nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"Error",
+			E(NotExist, Code("0404"), "no such user"),
+			`{"fault":{"code":"0404","detail":"no such user","errorType":"item_does_not_exist"}}`,
+		},
+		{
+			"ValidationErrors",
+			ValidationErrors{NewValidation("email", "email is required")},
+			`{"fault":{"detail":"email is required","errorType":"input_validation_error","errors":[{"detail":"email is required","errorType":"input_validation_error","param":"email"}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Body.String() != tt.want {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.want)
+			}
+			if got := w.Header().Get("Content-Type"); got != contentTypeJSON {
+				t.Errorf("HTTPErrorResponse() Content-Type = %q, want %q", got, contentTypeJSON)
+			}
+		})
+	}
+}
+
+func TestSetKindMessages(t *testing.T) {
+	SetKindMessages(map[Kind]string{
+		Database: "The service is unavailable, please try again later",
+	})
+	defer SetKindMessages(nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Kind message", E(Database, "pq: connection refused"), "The service is unavailable, please try again later"},
+		{"UserMessage", E(Database, UserMessage("Your order was not saved"), "pq: connection refused"), "Your order was not saved"},
+		{"other Kind", E(NotExist, "no such user"), "no such user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se, _ := ToServiceError(tt.err)
+			if se.Message != tt.want {
+				t.Errorf("ToServiceError() Message = %q, want %q", se.Message, tt.want)
+			}
+		})
+	}
+
+	SetDebug(true)
+	defer SetDebug(false)
+	if se, _ := ToServiceError(E(Database, "pq: connection refused")); !strings.Contains(se.Message, "pq: connection refused") {
+		t.Errorf("ToServiceError() Message = %q, want the complete error in debug mode", se.Message)
+	}
+}
+
+func TestSetSnakeCaseKinds(t *testing.T) {
+	SetSnakeCaseKinds(true)
+	defer SetSnakeCaseKinds(false)
+
+	tests := []struct {
+		name     string
+		err      error
+		wantBody string
+	}{
+		{"IO", E(IO, "disk full"), `{"error":{"kind":"io_error","message":"internal server error","retryable":true}}`},
+		{"registered Kind", E(quotaExceeded, "too many projects"), `{"error":{"kind":"quota_exceeded","message":"too many projects"}}`},
+		{"Join", Join(E(IO, "disk full"), E(quotaExceeded, "too many projects")),
+			`{"error":{"kind":"io_error","message":"internal server error; too many projects","errors":[{"kind":"io_error","message":"internal server error","retryable":true},{"kind":"quota_exceeded","message":"too many projects"}]}}`},
+		{"ValidationErrors", ValidationErrors{NewValidation("email", "email is invalid")},
+			`{"error":{"kind":"input_validation_error","message":"email is invalid","errors":[{"kind":"input_validation_error","param":"email","message":"email is invalid"}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+
+	SetSnakeCaseKinds(false)
+	if se, _ := ToServiceError(E(IO, "disk full")); se.Kind != KindIOString {
+		t.Errorf("ToServiceError() Kind = %q, want %q", se.Kind, KindIOString)
+	}
+}
+
+func TestHTTPErrorResponse_KindOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Kind", E(NotExist), `{"error":{"kind":"item_does_not_exist","message":"item does not exist"}}`},
+		{"Op", E(Op("repo.Find"), NotExist), `{"error":{"kind":"item_does_not_exist","message":"item does not exist"}}`},
+		{"Nested", E(Op("service.Find"), E(Op("repo.Find"), Validation, Parameter("id"))), `{"error":{"kind":"input_validation_error","param":"id","message":"input validation error"}}`},
+		{"Code", E(Invalid, Code("unregistered_code")), `{"error":{"kind":"invalid_operation","code":"unregistered_code","message":"invalid operation"}}`},
+		{"StripError", &Error{Kind: Exist, StripError: true}, `{"error":{"kind":"item_already_exists","message":"item already exists"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Body.String() != tt.want {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_CoalescedKind(t *testing.T) {
+	inner := fmt.Errorf("find user: %w", E(Op("repo.Find"), NotExist, Code("user_not_found"), "no such user"))
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			"inner Kind",
+			E(Op("service.Find"), inner),
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","code":"user_not_found","message":"no such user"}}`,
+		},
+		{
+			"outermost Kind",
+			E(Op("service.Find"), Internal, inner),
+			http.StatusInternalServerError,
+			`{"error":{"kind":"internal_error","code":"user_not_found","message":"internal server error"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != tt.wantCode {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_Unavailable(t *testing.T) {
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(Unavailable, "database pool is closed"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("HTTPErrorResponse() Connection = %q, want %q", got, "close")
+	}
+	want := `{"error":{"kind":"unavailable","message":"internal server error","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	// the Headers of the error replace those of its Kind
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(Unavailable, Headers{"Connection": "keep-alive"}, "database pool is closed"))
+	if got := w.Header().Get("Connection"); got != "keep-alive" {
+		t.Errorf("HTTPErrorResponse() Connection = %q, want %q", got, "keep-alive")
+	}
+}
+
+func TestSetMaskServerErrors(t *testing.T) {
+	err := E(Op("repo.Insert"), Database, "pq: relation \"users\" does not exist")
+
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.New(buf), err)
+	want := `{"error":{"kind":"database_error","message":"internal server error","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+	if !strings.Contains(buf.String(), `relation \"users\" does not exist`) {
+		t.Errorf("HTTPErrorResponse() log = %s, want the complete error", buf)
+	}
+
+	// client errors are not masked
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, "no such user"))
+	if want := `{"error":{"kind":"item_does_not_exist","message":"no such user"}}`; w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	SetMaskServerErrors(false)
+	defer SetMaskServerErrors(true)
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	want = `{"error":{"kind":"database_error","message":"pq: relation \"users\" does not exist","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
+
+func TestSetDefaultErrorHeaders(t *testing.T) {
+	SetDefaultErrorHeaders(http.Header{
+		"cache-control":          {"no-store"},
+		"X-Content-Type-Options": {"sniff"},
+		"Connection":             {"keep-alive"},
+	})
+	defer SetDefaultErrorHeaders(nil)
+
+	for _, err := range []error{E(NotExist, "no such user"), errors.New("boom"), E(Unauthenticated, "bad token"), nil} {
+		w := httptest.NewRecorder()
+		HTTPErrorResponse(w, zerolog.Nop(), err)
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("HTTPErrorResponse(%v) Cache-Control = %q, want %q", err, got, "no-store")
+		}
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("HTTPErrorResponse(%v) X-Content-Type-Options = %q, want %q", err, got, "nosniff")
+		}
+	}
+
+	// the headers of the Kind replace the default ones
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(Unavailable, "shutting down"))
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("HTTPErrorResponse() Connection = %q, want %q", got, "close")
+	}
+
+	// success responses are not affected
+	w = httptest.NewRecorder()
+	_ = WriteJSON(w, http.StatusOK, map[string]string{"name": "gilcrest"})
+	if got := w.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("WriteJSON() Cache-Control = %q, want none", got)
+	}
+}
+
+func TestHTTPErrorResponse_UserMessage(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	inner := E(Op("repo.Insert"), Database, UserMessage("We could not save your order, please try again"), "pq: duplicate key value violates unique constraint")
+	HTTPErrorResponse(w, zerolog.New(buf), E(Op("service.Create"), inner))
+
+	want := `{"error":{"kind":"database_error","message":"We could not save your order, please try again","retryable":true}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+	if !strings.Contains(buf.String(), "pq: duplicate key value") {
+		t.Errorf("HTTPErrorResponse() log = %s, want the internal message", buf)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := WriteJSON(w, http.StatusCreated, map[string]string{"id": "42"})
+	if err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("WriteJSON() status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got := w.Body.String(); got != `{"id":"42"}` {
+		t.Errorf("WriteJSON() body = %q", got)
+	}
+
+	// the headers match those of error responses
+	ew := httptest.NewRecorder()
+	HTTPErrorResponse(ew, zerolog.Nop(), E(NotExist, "no such user"))
+	for _, h := range []string{"Content-Type", "X-Content-Type-Options"} {
+		if w.Header().Get(h) != ew.Header().Get(h) {
+			t.Errorf("WriteJSON() %s = %q, error response has %q", h, w.Header().Get(h), ew.Header().Get(h))
+		}
+	}
+
+	w = httptest.NewRecorder()
+	if err := WriteJSON(w, http.StatusOK, func() {}); err == nil {
+		t.Error("WriteJSON(func) error = nil, want an encoding error")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("WriteJSON(func) wrote %q, want nothing", w.Body)
+	}
+}
+
+func TestHTTPErrorResponse_NoTrailingNewline(t *testing.T) {
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, "no such user"))
+	if strings.HasSuffix(w.Body.String(), "\n") {
+		t.Errorf("HTTPErrorResponse() body = %q, want no trailing newline", w.Body)
+	}
+	var er ErrResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil {
+		t.Errorf("HTTPErrorResponse() body %q is not JSON: %v", w.Body, err)
+	}
+}
+
+func TestHTTPErrorResponse_EncodingFailure(t *testing.T) {
+	// a body type which fails to marshal
+	SetErrorEncoder(func(se ServiceError, _ int) ([]byte, string, error) {
+		b, err := json.Marshal(struct {
+			ServiceError
+			Callback func() `json:"callback"`
+		}{ServiceError: se})
+		return b, "application/json", err
+	})
+	defer SetErrorEncoder(nil)
+
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.New(buf), E(NotExist, "no such user"))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w.Body.String() != fallbackBody {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, fallbackBody)
+	}
+	if !strings.Contains(buf.String(), "Error Response Encoding Failed") {
+		t.Errorf("HTTPErrorResponse() log = %s, want the encoding failure", buf)
+	}
+}
+
+func TestHTTPErrorResponse_Headers(t *testing.T) {
+	inner := E(Unauthenticated, Headers{"WWW-Authenticate": `Bearer realm="api"`, "X-Reason": "inner"})
+	err := E(Op("handler.Get"), Headers{"X-Reason": "outer"}, inner)
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	want := map[string]string{
+		"WWW-Authenticate": `Bearer realm="api"`,
+		"X-Reason":         "outer",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("HTTPErrorResponse() header %s = %q, want %q", k, got, v)
+		}
+	}
+	if inner.(*Error).Headers["X-Reason"] != "inner" {
+		t.Error("E() changed the Headers of the wrapped error")
+	}
+}
+
+func TestHTTPErrorResponse_Status(t *testing.T) {
+	err := E(Op("proxy.Get"), E(Permission, Status(http.StatusUnavailableForLegalReasons), "blocked in this country"))
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+
+	if w.Code != http.StatusUnavailableForLegalReasons {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusUnavailableForLegalReasons)
+	}
+	want := `{"error":{"kind":"permission_denied","message":"blocked in this country"}}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", got, want)
+	}
+}
+
+func TestWriteErrorResult(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Result
+	}{
+		{"error", E(NotExist, "no such user"), Result{StatusCode: http.StatusNotFound, BodyWritten: true}},
+		{"unauthenticated", E(Unauthenticated, "bad token"), Result{StatusCode: http.StatusUnauthorized}},
+		{"unauthorized", E(Unauthorized, "not an admin"), Result{StatusCode: http.StatusForbidden}},
+		{"empty error", &Error{}, Result{StatusCode: http.StatusBadRequest}},
+		{"nil error", nil, Result{StatusCode: http.StatusBadRequest}},
+		{"unknown error", errors.New("boom"), Result{StatusCode: http.StatusInternalServerError, BodyWritten: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			got, err := WriteErrorResult(w, nil, tt.err)
+			if err != nil {
+				t.Fatalf("WriteErrorResult() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("WriteErrorResult() = %+v, want %+v", got, tt.want)
+			}
+			if got.BodyWritten != (w.Body.Len() > 0) {
+				t.Errorf("WriteErrorResult() BodyWritten = %t, body %q", got.BodyWritten, w.Body)
+			}
+		})
+	}
+}
+
+func TestSetDebug_Causes(t *testing.T) {
+	err := E(Op("service.Find"), E(Op("repo.Find"), NotExist, fmt.Errorf("query user: %w", sql.ErrNoRows)))
+
+	se, _ := ToServiceError(err)
+	if se.Causes != nil {
+		t.Errorf("ToServiceError() Causes = %q, want none outside debug mode", se.Causes)
+	}
+
+	SetDebug(true)
+	defer SetDebug(false)
+	se, _ = ToServiceError(err)
+	want := []string{
+		"repo.Find|: query user: sql: no rows in result set",
+		"query user: sql: no rows in result set",
+		"sql: no rows in result set",
+	}
+	if !reflect.DeepEqual(se.Causes, want) {
+		t.Errorf("ToServiceError() Causes = %q, want %q", se.Causes, want)
+	}
+}
+
+func TestSetDebug(t *testing.T) {
+	err := E(Op("service.Find"), E(Op("repo.Find"), NotExist, UserMessage("user not found"), sql.ErrNoRows))
+	tests := []struct {
+		name  string
+		debug bool
+		err   error
+		want  string
+	}{
+		{"off", false, err, `"message":"user not found"`},
+		{"on", true, err, `"message":"service.Find: item_does_not_exist] repo.Find|: sql: no rows in result set"`},
+		{"off unknown error", false, errors.New("boom"), `"message":"Unexpected error - contact support"`},
+		{"on unknown error", true, errors.New("boom"), `"message":"boom"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDebug(tt.debug)
+			defer SetDebug(false)
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkHTTPErrorResponse(b *testing.B) {
+	err := E(Op("repo.Find"), NotExist, Code("user_not_found"), "no such user")
+	benchmarks := []struct {
+		name   string
+		logger zerolog.Logger
+	}{
+		{"Nop", zerolog.Nop()},
+		{"Logger", zerolog.New(ioutil.Discard)},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			w := httptest.NewRecorder()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w.Body.Reset()
+				HTTPErrorResponse(w, bm.logger, err)
+			}
+		})
+	}
+}
+
+func BenchmarkStatusCode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = statusCode(NotExist)
+	}
+}
+
+func TestStatusCode_NoAllocs(t *testing.T) {
+	// the mapping is built once at init, looking up a
+	// status code must not allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = statusCode(NotExist)
+		_ = statusCode(rateLimited)
+	})
+	if allocs != 0 {
+		t.Errorf("statusCode() allocs = %v, want 0", allocs)
+	}
+}
+
+func TestError_StatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want int
+	}{
+		{"Kind", E(NotExist, "no such user").(*Error), http.StatusNotFound},
+		{"Other", E("no kind").(*Error), http.StatusBadRequest},
+		{"registered Kind", E(rateLimited, "slow down").(*Error), http.StatusTooManyRequests},
+		{"unknown Kind", &Error{Kind: Kind(200)}, http.StatusInternalServerError},
+		{"Status", E(NotExist, Status(http.StatusUnavailableForLegalReasons)).(*Error), http.StatusUnavailableForLegalReasons},
+		{"Status pulled up", E(Op("proxy.Get"), E(Status(http.StatusUnavailableForLegalReasons), "blocked upstream")).(*Error), http.StatusUnavailableForLegalReasons},
+		{"Status below empty", &Error{Op: "outer", Err: &Error{Status: http.StatusNotAcceptable}}, http.StatusNotAcceptable},
+		{"nil", nil, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.StatusCode(); got != tt.want {
+				t.Errorf("StatusCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToServiceError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     ServiceError
+		wantCode int
+	}{
+		{"nil", nil, ServiceError{}, http.StatusBadRequest},
+		{
+			"*Error",
+			E(Op("repo.Find"), NotExist, Code("user_not_found"), Parameter("id"), "no such user"),
+			ServiceError{Kind: NotExist.String(), Code: "user_not_found", Param: "id", Message: "no such user"},
+			http.StatusNotFound,
+		},
+		{
+			"unauthenticated",
+			E(Unauthenticated, "bad token"),
+			ServiceError{Kind: Unauthenticated.String(), Message: "bad token"},
+			http.StatusUnauthorized,
+		},
+		{
+			"validation errors",
+			ValidationErrors{NewValidation("email", "email is required")},
+			ServiceError{
+				Kind:    Validation.String(),
+				Message: "email is required",
+				Errors:  []ServiceError{{Kind: Validation.String(), Param: "email", Message: "email is required"}},
+			},
+			http.StatusBadRequest,
+		},
+		{
+			"unknown error",
+			errors.New("boom"),
+			ServiceError{Kind: Unanticipated.String(), Message: "Unexpected error - contact support"},
+			http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotCode := ToServiceError(tt.err)
+			if !reflect.DeepEqual(got, tt.want) || gotCode != tt.wantCode {
+				t.Errorf("ToServiceError() = %+v, %d, want %+v, %d", got, gotCode, tt.want, tt.wantCode)
+			}
+			if se, code := EncodeError(tt.err); !reflect.DeepEqual(se, got) || code != gotCode {
+				t.Errorf("EncodeError() = %+v, %d, want %+v, %d", se, code, got, gotCode)
+			}
+			// HTTPErrorResponse sends the same ServiceError
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != gotCode {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, gotCode)
+			}
+			if w.Body.Len() > 0 {
+				body, _ := json.Marshal(ErrResponse{Error: got})
+				if w.Body.String() != string(body) {
+					t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, body)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_ParamValue(t *testing.T) {
+	err := E(Op("handler.Create"), E(Op("user.Validate"), Validation, Parameter("age"), ParamValue("1234"), "age must be below 150"))
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	want := `{"error":{"kind":"input_validation_error","param":"age","value":"1234","message":"age must be below 150"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	digits := regexp.MustCompile(`[0-9]+`)
+	SetRedactor(func(s string) string { return digits.ReplaceAllString(s, "***") })
+	defer SetRedactor(nil)
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	want = `{"error":{"kind":"input_validation_error","param":"age","value":"***","message":"age must be below ***"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
+
+func TestSetUnanticipatedResponse(t *testing.T) {
+	SetUnanticipatedResponse(ServiceError{Code: "oops", Message: "Something went wrong, see https://example.com/support"})
+	defer SetUnanticipatedResponse(ServiceError{})
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unknown error", errors.New("boom"), `{"error":{"kind":"unanticipated_error","code":"oops","message":"Something went wrong, see https://example.com/support"}}`},
+		{"*Error", E(NotExist, "no such user"), `{"error":{"kind":"item_does_not_exist","message":"no such user"}}`},
+		{"joined", Join(errors.New("boom"), E(NotExist, "no such user")), `{"error":{"kind":"unanticipated_error","message":"Something went wrong, see https://example.com/support; no such user","errors":[{"kind":"unanticipated_error","message":"Something went wrong, see https://example.com/support"},{"kind":"item_does_not_exist","message":"no such user"}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Body.String() != tt.want {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.want)
+			}
+		})
+	}
+
+	SetUnanticipatedResponse(ServiceError{})
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), errors.New("boom"))
+	want := `{"error":{"kind":"unanticipated_error","message":"Unexpected error - contact support"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s after restoring the default", w.Body, want)
+	}
+}
+
+func TestSetFallbackCode(t *testing.T) {
+	err := errors.New("boom")
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	want := `{"error":{"kind":"unanticipated_error","message":"Unexpected error - contact support"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	SetFallbackCode("internal")
+	defer SetFallbackCode("")
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	want = `{"error":{"kind":"unanticipated_error","code":"internal","message":"Unexpected error - contact support"}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
@@ -0,0 +1,110 @@
+package errs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// MessageTranslator returns the message for code in the language
+// lang, a BCP 47 language tag such as "fr" or "en-US", and reports
+// whether a translation exists.
+type MessageTranslator func(code Code, lang string) (string, bool)
+
+var (
+	translatorMu sync.RWMutex
+	// translator is the MessageTranslator set through
+	// SetMessageTranslator
+	translator MessageTranslator
+)
+
+// SetMessageTranslator sets the MessageTranslator used to localize
+// the messages sent to the client. A message is translated when
+// the response has a language, see HTTPErrorResponseLang and
+// NewContextWithLanguage, and the error has a Code. When fn has no
+// translation, the original message is sent. The logged error is
+// not translated. Passing nil restores the default, which sends
+// messages untranslated.
+//
+// SetMessageTranslator is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetMessageTranslator(fn MessageTranslator) {
+	translatorMu.Lock()
+	translator = fn
+	translatorMu.Unlock()
+}
+
+// messageTranslator returns the MessageTranslator set through
+// SetMessageTranslator, or nil
+func messageTranslator() MessageTranslator {
+	translatorMu.RLock()
+	defer translatorMu.RUnlock()
+	return translator
+}
+
+// translateServiceError returns a copy of se with the messages of
+// se and its Errors translated to lang by fn
+func translateServiceError(se ServiceError, fn MessageTranslator, lang string) ServiceError {
+	if fn == nil || lang == "" {
+		return se
+	}
+	if se.Code != "" {
+		if msg, ok := fn(Code(se.Code), lang); ok {
+			se.Message = msg
+		}
+	}
+	if len(se.Errors) > 0 {
+		errs := make([]ServiceError, len(se.Errors))
+		for i, ve := range se.Errors {
+			errs[i] = translateServiceError(ve, fn, lang)
+		}
+		se.Errors = errs
+	}
+	return se
+}
+
+// HTTPErrorResponseLang behaves like HTTPErrorResponse, but sends
+// the message translated to lang by the MessageTranslator set
+// through SetMessageTranslator.
+func HTTPErrorResponseLang(w http.ResponseWriter, logger zerolog.Logger, err error, lang string) {
+	_, _ = WriteErrorLang(w, zerologLogger(logger), err, lang)
+}
+
+// WriteErrorLang sends err as a response to the client exactly as
+// HTTPErrorResponseLang does, but logs through lgr, as WriteError
+// does, and returns the HTTP Status Code that was sent and any error
+// from writing the response body to w. If lgr is nil, nothing is
+// logged.
+func WriteErrorLang(w http.ResponseWriter, lgr Logger, err error, lang string) (int, error) {
+	r := response{w: w, lgr: lgr, encode: errorEncoder(), lang: lang}
+	res, werr := r.write(err)
+	return res.StatusCode, werr
+}
+
+// languageKey is the context key for the response language
+type languageKey struct{}
+
+// NewContextWithLanguage returns a copy of ctx carrying the language
+// lang, which HTTPErrorResponseCtx uses to translate messages as
+// HTTPErrorResponseLang does.
+func NewContextWithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// LanguageFromContext returns the language stored in ctx by
+// NewContextWithLanguage, if any.
+func LanguageFromContext(ctx context.Context) (string, bool) {
+	lang, ok := ctx.Value(languageKey{}).(string)
+	return lang, ok
+}
+
+// LanguageFromRequest returns the language preferred by the client,
+// the tag with the highest quality in the Accept-Language header of
+// r, or an empty string if there is none.
+func LanguageFromRequest(r *http.Request) string {
+	return preferred(r.Header.Get("Accept-Language"), func(tag string) bool {
+		return tag != "*"
+	})
+}
@@ -0,0 +1,76 @@
+package errs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestHTTPErrorResponseLang(t *testing.T) {
+	SetMessageTranslator(func(code Code, lang string) (string, bool) {
+		if code == "user_missing" && lang == "fr" {
+			return "utilisateur introuvable", true
+		}
+		return "", false
+	})
+	defer SetMessageTranslator(nil)
+
+	tests := []struct {
+		name     string
+		err      error
+		lang     string
+		wantBody string
+	}{
+		{"translated", E(NotExist, Code("user_missing"), "no such user"), "fr", `{"error":{"kind":"item_does_not_exist","code":"user_missing","message":"utilisateur introuvable"}}`},
+		{"no translation", E(NotExist, Code("user_missing"), "no such user"), "de", `{"error":{"kind":"item_does_not_exist","code":"user_missing","message":"no such user"}}`},
+		{"no language", E(NotExist, Code("user_missing"), "no such user"), "", `{"error":{"kind":"item_does_not_exist","code":"user_missing","message":"no such user"}}`},
+		{"no code", E(NotExist, "no such user"), "fr", `{"error":{"kind":"item_does_not_exist","message":"no such user"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponseLang(w, zerolog.Nop(), tt.err, tt.lang)
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponseLang() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+
+	lgr := &recordingLogger{}
+	w := httptest.NewRecorder()
+	code, err := WriteErrorLang(w, lgr, E(NotExist, Code("user_missing"), "no such user"), "fr")
+	if code != http.StatusNotFound || err != nil {
+		t.Errorf("WriteErrorLang() = %d, %v, want %d, nil", code, err, http.StatusNotFound)
+	}
+	if want := tests[0].wantBody; w.Body.String() != want {
+		t.Errorf("WriteErrorLang() body = %s, want %s", w.Body, want)
+	}
+	if lgr.err == nil {
+		t.Error("WriteErrorLang() did not log the error")
+	}
+}
+
+func TestLanguageFromRequest(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"fr", "fr"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "fr-CH"},
+		{"en;q=0.5, de;q=0.8", "de"},
+		{"*, es;q=0.7", "es"},
+		{"en;q=bad, it;q=0.1", "it"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Accept-Language", tt.header)
+		}
+		if got := LanguageFromRequest(r); got != tt.want {
+			t.Errorf("LanguageFromRequest(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
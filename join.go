@@ -0,0 +1,112 @@
+package errs
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// joinError is the error returned by Join
+type joinError struct {
+	// kind is the most severe Kind of errs
+	kind Kind
+	errs []error
+}
+
+// Join returns an error that wraps the given errors, e.g. the
+// errors of a batch operation, so they can be returned together.
+// Any nil errors are discarded and Join returns nil if every error
+// is nil. The Error method joins the messages of the errors with
+// "; " and Unwrap returns the errors.
+//
+// The Kind of the returned error, as reported by KindOf, is the most
+// severe Kind of the errors, where an error not from this package
+// counts as Unanticipated. Server error Kinds, such as Internal or
+// Database, are more severe than client error Kinds, such as
+// Validation or NotExist. HTTPErrorResponse sends the error with the
+// HTTP Status Code of that Kind and one ServiceError per error in
+// Errors, unless that Kind is Unauthenticated or Unauthorized, which
+// are sent with an empty body, as for a single such error.
+func Join(errs ...error) error {
+	j := &joinError{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		j.errs = append(j.errs, err)
+		if k := joinKind(err); severity(k) > severity(j.kind) {
+			j.kind = k
+		}
+	}
+	if len(j.errs) == 0 {
+		return nil
+	}
+	return j
+}
+
+func (j *joinError) Error() string {
+	msgs := make([]string, len(j.errs))
+	for i, err := range j.errs {
+		if e, ok := err.(*Error); ok {
+			msgs[i] = stripStack(e)
+			continue
+		}
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (j *joinError) packageError() {}
+
+// Unwrap returns the joined errors
+func (j *joinError) Unwrap() []error {
+	return j.errs
+}
+
+// joinKind returns the Kind of an error given to Join, errors not
+// from this package and not wrapping one are Unanticipated
+func joinKind(err error) Kind {
+	var pe packageError
+	if !errors.As(err, &pe) {
+		return Unanticipated
+	}
+	return KindOf(err)
+}
+
+// severity orders Kinds from least to most severe, for choosing the
+// Kind of the error returned by Join. Client errors (HTTP 4xx) are
+// less severe than server errors (HTTP 5xx) and, within each group,
+// the more specific a Kind the more severe it is. A Kind registered
+// through RegisterKind ranks with the built-in Kinds of its group.
+func severity(k Kind) int {
+	switch k {
+	case Other, Canceled:
+		return 0
+	case Invalid, InvalidRequest, Private, BrokenLink, Validation, MethodNotAllowed:
+		return 1
+	case NotExist:
+		return 2
+	case Exist, Conflict:
+		return 3
+	case TooManyRequests:
+		return 4
+	case Unauthenticated:
+		return 5
+	case Permission, Unauthorized:
+		return 6
+	case Timeout, Unavailable:
+		return 7
+	case IO:
+		return 8
+	case Database:
+		return 9
+	case Internal:
+		return 10
+	case Unanticipated:
+		return 11
+	}
+	if statusCode(k) >= http.StatusInternalServerError {
+		return 10
+	}
+	return 1
+}
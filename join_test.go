@@ -0,0 +1,128 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestJoin(t *testing.T) {
+	if err := Join(nil, nil); err != nil {
+		t.Errorf("Join(nil, nil) = %v, want nil", err)
+	}
+
+	notExist := E(NotExist, Parameter("id"), "no such user")
+	invalid := E(Validation, Parameter("email"), "email is required")
+	tests := []struct {
+		name     string
+		errs     []error
+		wantKind Kind
+		wantCode int
+		wantBody string
+	}{
+		{
+			"client errors",
+			[]error{invalid, nil, notExist},
+			NotExist,
+			http.StatusNotFound,
+			`{"error":{"kind":"item_does_not_exist","message":"email is required; no such user","errors":[{"kind":"input_validation_error","param":"email","message":"email is required"},{"kind":"item_does_not_exist","param":"id","message":"no such user"}]}}`,
+		},
+		{
+			"server error",
+			[]error{invalid, E(Database, "connection refused")},
+			Database,
+			http.StatusInternalServerError,
+			`{"error":{"kind":"database_error","message":"email is required; internal server error","errors":[{"kind":"input_validation_error","param":"email","message":"email is required"},{"kind":"database_error","message":"internal server error","retryable":true}]}}`,
+		},
+		{
+			"error not from this package",
+			[]error{errors.New("disk full"), invalid},
+			Unanticipated,
+			http.StatusInternalServerError,
+			`{"error":{"kind":"unanticipated_error","message":"Unexpected error - contact support; email is required","errors":[{"kind":"unanticipated_error","message":"Unexpected error - contact support"},{"kind":"input_validation_error","param":"email","message":"email is required"}]}}`,
+		},
+		{
+			"unauthenticated",
+			[]error{E(Unauthenticated, "token abc123 expired")},
+			Unauthenticated,
+			http.StatusUnauthorized,
+			``,
+		},
+		{
+			"unauthorized",
+			[]error{invalid, E(Unauthorized, "user 42 is not an admin")},
+			Unauthorized,
+			http.StatusForbidden,
+			``,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Join(tt.errs...)
+			if got := KindOf(err); got != tt.wantKind {
+				t.Errorf("KindOf(Join()) = %v, want %v", got, tt.wantKind)
+			}
+			for _, je := range tt.errs {
+				if je != nil && !errors.Is(err, je) {
+					t.Errorf("errors.Is(Join(), %v) = false, want true", je)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), err)
+			if w.Code != tt.wantCode {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestJoin_Wrapped(t *testing.T) {
+	joined := Join(E(Validation, Parameter("email"), "email is required"), E(Internal, "queue is full"))
+	const wantBody = `{"error":{"kind":"internal_error","message":"email is required; internal server error","errors":[{"kind":"input_validation_error","param":"email","message":"email is required"},{"kind":"internal_error","message":"internal server error"}]}}`
+	tests := []struct {
+		name     string
+		err      error
+		wantKind Kind
+	}{
+		{"fmt.Errorf", fmt.Errorf("batch: %w", joined), Internal},
+		{"Op only", E(Op("batch.Run"), joined), Internal},
+		{"outer Kind wins", E(Unavailable, fmt.Errorf("batch: %w", joined)), Unavailable},
+		{"joined again", Join(fmt.Errorf("batch: %w", joined), E(NotExist, "no such user")), Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.wantKind {
+				t.Errorf("KindOf() = %v, want %v", got, tt.wantKind)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), fmt.Errorf("batch: %w", joined))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if w.Body.String() != wantBody {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, wantBody)
+	}
+
+	// an outer Kind sets the status, the joined errors are still
+	// sent with the message of each as for the Join itself
+	w = httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), E(Conflict, Op("batch.Run"), joined))
+	const wantConflict = `{"error":{"kind":"conflict","message":"email is required; internal server error","errors":[{"kind":"input_validation_error","param":"email","message":"email is required"},{"kind":"internal_error","message":"internal server error"}]}}`
+	if w.Code != http.StatusConflict {
+		t.Errorf("HTTPErrorResponse(E(Conflict)) status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w.Body.String() != wantConflict {
+		t.Errorf("HTTPErrorResponse(E(Conflict)) body = %s, want %s", w.Body, wantConflict)
+	}
+}
@@ -0,0 +1,205 @@
+package errs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/rs/zerolog"
+)
+
+// Logger is the interface used to log errors as they are sent to
+// the client. Implement it to use any logging library.
+//
+// Messages and field values built from user input, such as the
+// message of an unknown error, a Param or the string values of the
+// Meta of an error, have their control characters escaped, so they
+// cannot forge log lines. The error itself is passed unchanged, the
+// JSON output of zerolog and log/slog already escapes it, but a
+// Logger writing plain text must escape it to prevent log injection.
+type Logger interface {
+	// LogError logs at error level. err may be nil, msg may be
+	// empty and fields holds any structured data related to err.
+	LogError(err error, msg string, fields map[string]interface{})
+}
+
+// Level is the level a LevelLogger logs at, so a Logger for any
+// logging library can implement LevelLogger without depending on
+// zerolog
+type Level int8
+
+// The levels errors are logged at, from the least to the most severe
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// LevelLogger is a Logger which can also log at other levels than
+// error level. If the Logger given to WriteError and its variants is
+// a LevelLogger, errors are logged at the level of their Kind, see
+// SetLogLevels.
+type LevelLogger interface {
+	Logger
+	// LogAt logs at the given level, with the same arguments
+	// as LogError.
+	LogAt(level Level, err error, msg string, fields map[string]interface{})
+}
+
+// ZerologLogger adapts a zerolog.Logger to the Logger and
+// LevelLogger interfaces, e.g. ZerologLogger(logger).
+type ZerologLogger zerolog.Logger
+
+// LogError logs err and fields as an error level zerolog event
+func (l ZerologLogger) LogError(err error, msg string, fields map[string]interface{}) {
+	l.LogAt(ErrorLevel, err, msg, fields)
+}
+
+// LogAt logs err and fields as a zerolog event of the given level
+func (l ZerologLogger) LogAt(level Level, err error, msg string, fields map[string]interface{}) {
+	logger := zerolog.Logger(l)
+	event := logger.WithLevel(zerologLevel(level))
+	if err != nil {
+		event = event.Err(err)
+	}
+	event.Fields(fields).Msg(msg)
+}
+
+// zerologLevel returns the zerolog.Level for a Level
+func zerologLevel(level Level) zerolog.Level {
+	switch level {
+	case DebugLevel:
+		return zerolog.DebugLevel
+	case InfoLevel:
+		return zerolog.InfoLevel
+	case WarnLevel:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// zerologLogger returns ZerologLogger(l), or nil if l is disabled,
+// e.g. zerolog.Nop(), so no log fields are built for it
+func zerologLogger(l zerolog.Logger) Logger {
+	if l.GetLevel() == zerolog.Disabled {
+		return nil
+	}
+	return ZerologLogger(l)
+}
+
+var (
+	logLevelsMu sync.RWMutex
+	// logLevels holds the levels set through SetLogLevels
+	logLevels map[Kind]Level
+)
+
+// SetLogLevels overrides the level errors are logged at for each Kind
+// present in m. By default, errors sent with an HTTP 5xx Status Code,
+// such as Internal or Database errors, or errors whose Status or
+// registered Code, see RegisterCode, is a 5xx one, are logged at
+// error level and all other errors, usually client mistakes such as
+// NotExist or Validation, at warn level. Each call replaces the overrides from
+// any previous call; passing a nil map restores the defaults.
+//
+// Levels only apply to a LevelLogger, such as ZerologLogger, any
+// other Logger logs every error with LogError.
+//
+// SetLogLevels is typically called once during program
+// initialization, but it is safe for concurrent use.
+func SetLogLevels(m map[Kind]Level) {
+	overrides := make(map[Kind]Level, len(m))
+	for k, v := range m {
+		overrides[k] = v
+	}
+	logLevelsMu.Lock()
+	logLevels = overrides
+	logLevelsMu.Unlock()
+}
+
+// logLevel returns the level errors of Kind k sent with the HTTP
+// Status Code status are logged at
+func logLevel(k Kind, status int) Level {
+	logLevelsMu.RLock()
+	level, ok := logLevels[k]
+	logLevelsMu.RUnlock()
+	if ok {
+		return level
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrorLevel
+	}
+	return WarnLevel
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler, so e is
+// logged as an object with the same fields as MarshalJSON, e.g. with
+// logger.Error().Object("error", e). As Event.Err logs an error that
+// implements zerolog.LogObjectMarshaler this way, it is also how
+// ZerologLogger logs e.
+func (e *Error) MarshalZerologObject(event *zerolog.Event) {
+	if e == nil {
+		return
+	}
+	event.Str("kind", KindOf(e).String())
+	if code := CodeOf(e); code != "" {
+		event.Str("code", string(code))
+	}
+	if e.Param != "" {
+		event.Str("param", string(e.Param))
+	}
+	if e.Path != "" {
+		event.Str("path", string(e.Path))
+	}
+	if e.User != "" {
+		event.Str("user", string(e.User))
+	}
+	if ops := Ops(e); len(ops) > 0 {
+		strs := make([]string, len(ops))
+		for i, op := range ops {
+			strs[i] = string(op)
+		}
+		event.Strs("ops", strs)
+	}
+	if msg := stripStack(e); msg != "" {
+		event.Str("message", msg)
+	}
+}
+
+// metaField returns the value v of a Meta entry as it is logged, with
+// the control characters of a string escaped, as Meta may hold values
+// taken from user input, e.g. the value noted by InvalidQueryParam
+func metaField(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return sanitize(s)
+	}
+	return v
+}
+
+// sanitize escapes the control characters of s, such as newlines or
+// ANSI escape codes, so values taken from user input, e.g. a Param,
+// cannot forge log lines, whatever the output format of the Logger.
+func sanitize(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) == -1 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package errs
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestError_MarshalZerologObject(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{
+			"full error",
+			E(Op("service.Create"), E(Op("repo.Insert"), Exist, Code("email_taken"), Parameter("email"), "email already registered")).(*Error),
+			`{"error":{"kind":"item_already_exists","code":"email_taken","param":"email","ops":["service.Create","repo.Insert"],"message":"email already registered"}}`,
+		},
+		{
+			"nil Err",
+			&Error{Kind: NotExist, Op: "repo.Find"},
+			`{"error":{"kind":"item_does_not_exist","ops":["repo.Find"],"message":"repo.Find: item_does_not_exist"}}`,
+		},
+		{
+			"nil error",
+			nil,
+			`{"error":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			logger := zerolog.New(buf)
+			logger.Log().Object("error", tt.err).Send()
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("MarshalZerologObject() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLogLevels(t *testing.T) {
+	RegisterCode("upstream_down", http.StatusServiceUnavailable, "")
+	tests := []struct {
+		name   string
+		levels map[Kind]Level
+		err    error
+		want   string
+	}{
+		{"client error", nil, E(NotExist, "no such user"), `"level":"warn"`},
+		{"server error", nil, E(Database, "connection refused"), `"level":"error"`},
+		{"unknown error", nil, errors.New("boom"), `"level":"error"`},
+		{"server Status", nil, E(NotExist, Status(http.StatusBadGateway), "upstream failed"), `"level":"error"`},
+		{"server registered Code", nil, E(NotExist, Code("upstream_down"), "upstream failed"), `"level":"error"`},
+		{"override", map[Kind]Level{NotExist: InfoLevel}, E(NotExist, "no such user"), `"level":"info"`},
+		{"debug override", map[Kind]Level{NotExist: DebugLevel}, E(NotExist, "no such user"), `"level":"debug"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLogLevels(tt.levels)
+			defer SetLogLevels(nil)
+
+			buf := new(bytes.Buffer)
+			HTTPErrorResponse(httptest.NewRecorder(), zerolog.New(buf), tt.err)
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("HTTPErrorResponse() log = %s, want %s", buf, tt.want)
+			}
+		})
+	}
+}
+
+// recordingLogger records the fields of the last logged error
+type recordingLogger struct {
+	err    error
+	msg    string
+	fields map[string]interface{}
+}
+
+func (l *recordingLogger) LogError(err error, msg string, fields map[string]interface{}) {
+	l.err, l.msg, l.fields = err, msg, fields
+}
+
+func TestLogFields_Sanitized(t *testing.T) {
+	forged := "email\n{\"level\":\"info\",\"message\":\"forged\"}\x1b[2J"
+	want := `email\n{"level":"info","message":"forged"}\u001b[2J`
+
+	lgr := &recordingLogger{}
+	_, _ = WriteError(httptest.NewRecorder(), lgr, E(Validation, Parameter(forged), Code(forged), "bad input"))
+	for _, k := range []string{"Parameter", "Code"} {
+		if got := lgr.fields[k]; got != want {
+			t.Errorf("logged %s = %q, want %q", k, got, want)
+		}
+	}
+
+	_, _ = WriteError(httptest.NewRecorder(), lgr, ValidationErrors{NewValidation(Parameter(forged), "bad input")})
+	if got := lgr.fields["Parameters"].([]string); len(got) != 1 || got[0] != want {
+		t.Errorf("logged Parameters = %q, want [%q]", got, want)
+	}
+}
+
+func TestLogFields_RequestParamSanitized(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/items?limit="+url.QueryEscape("-5\n{\"level\":\"info\"}"), nil)
+	want := `-5\n{"level":"info"}`
+
+	lgr := &recordingLogger{}
+	_, _ = WriteError(httptest.NewRecorder(), lgr, InvalidQueryParam(r, "limit", "limit must be positive"))
+	if got := lgr.fields["param_value"]; got != want {
+		t.Errorf("logged param_value = %q, want %q", got, want)
+	}
+
+	// the Meta of each of several validation errors is logged too
+	_, _ = WriteError(httptest.NewRecorder(), lgr, ValidationErrors{
+		InvalidQueryParam(r, "limit", "limit must be positive"),
+		NewValidation("sort", "sort is invalid"),
+	})
+	meta, ok := lgr.fields["ParameterMeta"].(map[string]Meta)
+	if !ok {
+		t.Fatalf("logged ParameterMeta = %#v, want a map[string]Meta", lgr.fields["ParameterMeta"])
+	}
+	wantMeta := map[string]Meta{"limit": {"param_source": "query", "param_value": want}}
+	if !reflect.DeepEqual(meta, wantMeta) {
+		t.Errorf("logged ParameterMeta = %v, want %v", meta, wantMeta)
+	}
+}
+
+func TestLogMessage_Sanitized(t *testing.T) {
+	err := errors.New("boom\n{\"level\":\"info\",\"message\":\"forged\"}")
+
+	lgr := &recordingLogger{}
+	_, _ = WriteError(httptest.NewRecorder(), lgr, err)
+	if strings.ContainsAny(lgr.msg, "\n\r") {
+		t.Errorf("logged message = %q, want control characters escaped", lgr.msg)
+	}
+
+	// zerolog escapes the logged error itself
+	buf := new(bytes.Buffer)
+	HTTPErrorResponse(httptest.NewRecorder(), zerolog.New(buf), E(Validation, err))
+	if lines := strings.Count(strings.TrimSpace(buf.String()), "\n"); lines != 0 {
+		t.Errorf("log output = %q, want a single line", buf)
+	}
+}
+
+func TestLogFields_Meta(t *testing.T) {
+	inner := E(Op("repo.Find"), NotExist, Meta{"TenantID": "t-1", "UserID": "u-1"}, "no such user")
+	err := E(Op("service.Find"), Meta{"UserID": "u-2", "Kind": "forged"}, inner)
+
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.New(buf), err)
+
+	for _, want := range []string{`"TenantID":"t-1"`, `"UserID":"u-2"`, `"Kind":"item_does_not_exist"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTTPErrorResponse() log = %s, want %s", buf, want)
+		}
+	}
+	if strings.Contains(w.Body.String(), "t-1") || strings.Contains(w.Body.String(), "u-2") {
+		t.Errorf("HTTPErrorResponse() body = %s, Meta must only be logged", w.Body)
+	}
+}
@@ -0,0 +1,153 @@
+package errs
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// contentTypeXML is the content type of the bodies encoded by
+// XMLEncoder
+const contentTypeXML = "application/xml; charset=utf-8"
+
+// xmlServiceError is the XML counterpart of ServiceError, with the
+// same element names as the JSON field names
+type xmlServiceError struct {
+	XMLName       xml.Name   `xml:"error"`
+	Kind          string     `xml:"kind,omitempty"`
+	Code          string     `xml:"code,omitempty"`
+	Param         string     `xml:"param,omitempty"`
+	Value         string     `xml:"value,omitempty"`
+	Message       string     `xml:"message,omitempty"`
+	Retryable     bool       `xml:"retryable,omitempty"`
+	CorrelationID string     `xml:"correlation_id,omitempty"`
+	Errors        *xmlErrors `xml:"errors,omitempty"`
+	Causes        *xmlCauses `xml:"causes,omitempty"`
+}
+
+// xmlErrors holds the Errors of a ServiceError, a pointer is used so
+// the <errors> element is omitted when there are none
+type xmlErrors struct {
+	Errors []xmlServiceError `xml:"error"`
+}
+
+// xmlCauses holds the Causes of a ServiceError
+type xmlCauses struct {
+	Causes []string `xml:"cause"`
+}
+
+// newXMLServiceError returns the xmlServiceError for se
+func newXMLServiceError(se ServiceError) xmlServiceError {
+	xse := xmlServiceError{
+		Kind:          se.Kind,
+		Code:          se.Code,
+		Param:         se.Param,
+		Value:         se.Value,
+		Message:       se.Message,
+		Retryable:     se.Retryable,
+		CorrelationID: se.CorrelationID,
+	}
+	if len(se.Errors) > 0 {
+		xse.Errors = &xmlErrors{Errors: make([]xmlServiceError, len(se.Errors))}
+		for i, e := range se.Errors {
+			xse.Errors.Errors[i] = newXMLServiceError(e)
+		}
+	}
+	if len(se.Causes) > 0 {
+		xse.Causes = &xmlCauses{Causes: se.Causes}
+	}
+	return xse
+}
+
+// XMLEncoder is an ErrorEncoder which encodes se as XML, within an
+// <error> element holding one element per field of se, named as in
+// JSON, e.g.
+//
+//	<error><kind>item_does_not_exist</kind><message>no such user</message></error>
+//
+// The Errors of se are sent as <error> elements within an <errors>
+// element.
+func XMLEncoder(se ServiceError, _ int) ([]byte, string, error) {
+	b, err := xml.Marshal(newXMLServiceError(se))
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]byte(xml.Header), b...), contentTypeXML, nil
+}
+
+// HTTPErrorResponseNegotiate behaves like HTTPErrorResponse, but
+// chooses the format of the response body from the Accept header of
+// req: if the client prefers application/xml or text/xml the body is
+// encoded by XMLEncoder, otherwise, including when there is no Accept
+// header, by the ErrorEncoder set through SetErrorEncoder, by default
+// as JSON. No body is sent in response to a HEAD request, see
+// Handler.
+func HTTPErrorResponseNegotiate(w http.ResponseWriter, req *http.Request, logger zerolog.Logger, err error) {
+	_, _ = WriteErrorNegotiate(w, req, zerologLogger(logger), err)
+}
+
+// WriteErrorNegotiate sends err as a response to the client exactly
+// as HTTPErrorResponseNegotiate does, but logs through lgr, as
+// WriteError does, and returns the HTTP Status Code that was sent and
+// any error from writing the response body to w. If lgr is nil,
+// nothing is logged.
+func WriteErrorNegotiate(w http.ResponseWriter, req *http.Request, lgr Logger, err error) (int, error) {
+	r := response{w: w, lgr: lgr, encode: errorEncoder(), head: req.Method == http.MethodHead}
+	if prefersXML(req) {
+		r.encode = XMLEncoder
+	}
+	res, werr := r.write(err)
+	return res.StatusCode, werr
+}
+
+// prefersXML reports whether the Accept header of r ranks an XML
+// media type above JSON
+func prefersXML(r *http.Request) bool {
+	mt := preferred(r.Header.Get("Accept"), func(mt string) bool {
+		switch strings.ToLower(mt) {
+		case "application/xml", "text/xml", "application/json", "application/*", "*/*":
+			return true
+		}
+		return false
+	})
+	mt = strings.ToLower(mt)
+	return mt == "application/xml" || mt == "text/xml"
+}
+
+// preferred returns the value with the highest quality in header, a
+// comma separated list of values with an optional q parameter, such
+// as Accept or Accept-Language, among those for which ok returns
+// true. Of values with the same quality, the first one is returned.
+// preferred returns an empty string if there is none.
+func preferred(header string, ok func(value string) bool) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value, q := strings.TrimSpace(params[0]), 1.0
+		valid := true
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[len("q="):], 64)
+				if err != nil {
+					valid = false
+					break
+				}
+				q = v
+			}
+		}
+		if !valid || value == "" || !ok(value) {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = value, q
+		}
+	}
+	return best
+}
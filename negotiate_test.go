@@ -0,0 +1,87 @@
+package errs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestHTTPErrorResponseNegotiate(t *testing.T) {
+	err := ValidationErrors{NewValidation("email", "email is required")}
+	const (
+		jsonBody = `{"error":{"kind":"input_validation_error","message":"email is required","errors":[{"kind":"input_validation_error","param":"email","message":"email is required"}]}}`
+		xmlBody  = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<error><kind>input_validation_error</kind><message>email is required</message><errors><error><kind>input_validation_error</kind><param>email</param><message>email is required</message></error></errors></error>`
+	)
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"no Accept", "", contentTypeJSON, jsonBody},
+		{"JSON", "application/json", contentTypeJSON, jsonBody},
+		{"XML", "application/xml", contentTypeXML, xmlBody},
+		{"text XML", "text/xml; charset=utf-8", contentTypeXML, xmlBody},
+		{"XML preferred", "application/json;q=0.5, application/xml", contentTypeXML, xmlBody},
+		{"JSON preferred", "application/xml;q=0.8, application/json", contentTypeJSON, jsonBody},
+		{"anything", "*/*", contentTypeJSON, jsonBody},
+		{"unsupported", "text/html", contentTypeJSON, jsonBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			HTTPErrorResponseNegotiate(w, req, zerolog.Nop(), err)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTPErrorResponseNegotiate() status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("HTTPErrorResponseNegotiate() Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("HTTPErrorResponseNegotiate() body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponseNegotiate_Head(t *testing.T) {
+	req := httptest.NewRequest(http.MethodHead, "/users", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	HTTPErrorResponseNegotiate(w, req, zerolog.Nop(), E(NotExist, "no such user"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("HTTPErrorResponseNegotiate() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HTTPErrorResponseNegotiate() body = %s, want no body", w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != contentTypeXML {
+		t.Errorf("HTTPErrorResponseNegotiate() Content-Type = %q, want %q", got, contentTypeXML)
+	}
+	if w.Header().Get("Content-Length") == "" {
+		t.Error("HTTPErrorResponseNegotiate() Content-Length not set")
+	}
+}
+
+func TestWriteErrorNegotiate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept", "application/xml")
+	lgr := &recordingLogger{}
+	w := httptest.NewRecorder()
+	code, err := WriteErrorNegotiate(w, req, lgr, E(NotExist, "no such user"))
+	if code != http.StatusNotFound || err != nil {
+		t.Errorf("WriteErrorNegotiate() = %d, %v, want %d, nil", code, err, http.StatusNotFound)
+	}
+	if got := w.Header().Get("Content-Type"); got != contentTypeXML {
+		t.Errorf("WriteErrorNegotiate() Content-Type = %q, want %q", got, contentTypeXML)
+	}
+	if lgr.err == nil {
+		t.Error("WriteErrorNegotiate() did not log the error")
+	}
+}
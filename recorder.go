@@ -0,0 +1,125 @@
+package errs
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// StatusRecorder is an http.ResponseWriter which records the status
+// code written to it, e.g. by HTTPErrorResponse, so a middleware can
+// log it once the handler returns:
+//
+//	rec := errs.NewStatusRecorder(w)
+//	next.ServeHTTP(rec, r)
+//	logger.Info().Int("status", rec.Status()).Msg("request served")
+//
+// The error response is not sent to a StatusRecorder whose headers
+// were already sent, as the status cannot be changed anymore, the
+// error is logged with a warning instead. Handler wraps its
+// http.ResponseWriter in a StatusRecorder, unless it already is one,
+// for that reason.
+//
+// The optional interfaces of the underlying http.ResponseWriter,
+// http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom, remain
+// available through the StatusRecorder.
+type StatusRecorder struct {
+	http.ResponseWriter
+	// status is the status code written, zero if the headers
+	// were not sent yet
+	status int
+}
+
+// NewStatusRecorder returns a StatusRecorder writing to w
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w}
+}
+
+// Status returns the status code written, or zero if the headers
+// were not sent yet
+func (w *StatusRecorder) Status() int {
+	return w.status
+}
+
+// WriteHeader records the status code and sends the headers. An
+// informational 1xx status code, such as http.StatusEarlyHints, is
+// sent but not recorded, as net/http sends the final status code and
+// headers later, so an error response can still be sent. Only
+// http.StatusSwitchingProtocols is final, as for net/http.
+func (w *StatusRecorder) WriteHeader(statusCode int) {
+	if w.status == 0 && (statusCode >= http.StatusOK || statusCode == http.StatusSwitchingProtocols) {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write sends the headers with http.StatusOK if they were not sent
+// yet, as the underlying http.ResponseWriter does, then writes b
+func (w *StatusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client, if the underlying
+// http.ResponseWriter supports it
+func (w *StatusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, e.g. for a
+// WebSocket upgrade, if the underlying http.ResponseWriter supports
+// it. The status is then recorded as http.StatusSwitchingProtocols,
+// as no error response can be sent anymore.
+func (w *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("errs: the http.ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// ReadFrom copies src to the response, using the io.ReaderFrom of
+// the underlying http.ResponseWriter if it has one, e.g. to send a
+// file with sendfile
+func (w *StatusRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{w.ResponseWriter}, src)
+}
+
+// Push initiates an HTTP/2 server push, if the underlying
+// http.ResponseWriter supports it
+func (w *StatusRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// writerOnly hides the methods of an io.Writer other than Write, so
+// io.Copy does not call ReadFrom again
+type writerOnly struct {
+	io.Writer
+}
+
+// Unwrap returns the underlying http.ResponseWriter
+func (w *StatusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
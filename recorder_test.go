@@ -0,0 +1,130 @@
+package errs
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		want  int
+	}{
+		{"nothing written", func(w http.ResponseWriter) {}, 0},
+		{"error response", func(w http.ResponseWriter) {
+			HTTPErrorResponse(w, zerolog.Nop(), E(NotExist, "no such user"))
+		}, http.StatusNotFound},
+		{"Write", func(w http.ResponseWriter) { _, _ = w.Write([]byte("ok")) }, http.StatusOK},
+		{"Flush", func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, http.StatusOK},
+		{"first WriteHeader wins", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusAccepted)
+			HTTPErrorResponse(w, zerolog.Nop(), E(Internal, "queue is full"))
+		}, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rec := NewStatusRecorder(w)
+			tt.write(rec)
+			if got := rec.Status(); got != tt.want {
+				t.Errorf("Status() = %d, want %d", got, tt.want)
+			}
+			if tt.want != 0 && w.Code != tt.want {
+				t.Errorf("underlying status = %d, want %d", w.Code, tt.want)
+			}
+			if rec.Unwrap() != w {
+				t.Error("Unwrap() did not return the underlying http.ResponseWriter")
+			}
+		})
+	}
+}
+
+func TestStatusRecorder_ReadFrom(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewStatusRecorder(w)
+	n, err := io.Copy(rec, strings.NewReader("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("io.Copy() = %d, %v, want 5, nil", n, err)
+	}
+	if rec.Status() != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("Status(), body = %d, %q, want %d, %q", rec.Status(), w.Body, http.StatusOK, "hello")
+	}
+}
+
+func TestStatusRecorder_Unsupported(t *testing.T) {
+	rec := NewStatusRecorder(httptest.NewRecorder())
+	if _, _, err := rec.Hijack(); err == nil {
+		t.Error("Hijack() error = nil, want an error for a writer which is not an http.Hijacker")
+	}
+	if err := rec.Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("Push() error = %v, want %v", err, http.ErrNotSupported)
+	}
+	if rec.Status() != 0 {
+		t.Errorf("Status() = %d, want 0", rec.Status())
+	}
+}
+
+func TestHandler_Hijack(t *testing.T) {
+	h := Handler(zerolog.Nop())(func(w http.ResponseWriter, r *http.Request) error {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			return E(Internal, "the http.ResponseWriter is not an http.Hijacker")
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			return E(Internal, err)
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		return rw.Flush()
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("http.Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(resp.Body)
+		t.Errorf("status = %d, want %d: %s", resp.StatusCode, http.StatusSwitchingProtocols, body)
+	}
+}
+
+func TestHandler_EarlyHints(t *testing.T) {
+	h := Handler(zerolog.Nop())(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		return E(NotExist, "no such user")
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("http.Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	const want = `{"error":{"kind":"item_does_not_exist","message":"no such user"}}`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+
+	rec := NewStatusRecorder(httptest.NewRecorder())
+	rec.WriteHeader(http.StatusEarlyHints)
+	if rec.Status() != 0 {
+		t.Errorf("Status() after %d = %d, want 0", http.StatusEarlyHints, rec.Status())
+	}
+}
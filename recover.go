@@ -0,0 +1,93 @@
+package errs
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// RecoverHandler recovers from a panic and sends it to the client
+// and logs it with HTTPErrorResponse, as an *Error of Kind Internal.
+// It must be deferred directly, typically at the top of an HTTP
+// handler or middleware:
+//
+//	defer errs.RecoverHandler(w, logger)
+//
+// The stack of the *Error, see StackTrace, is the stack where the
+// panic happened, not where it was recovered. If the panic value is
+// an error, it is wrapped, so errors.Is and errors.As find it. The
+// panic value is logged, but not sent to the client, which gets a
+// generic message instead. RecoverHandler does nothing if there is
+// no panic, and panics again with http.ErrAbortHandler, which
+// net/http uses to abort a response on purpose.
+func RecoverHandler(w http.ResponseWriter, logger zerolog.Logger) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	HTTPErrorResponse(w, logger, recovered(v, panicStack()))
+}
+
+// RecoverWithLogger behaves like RecoverHandler, but logs through
+// lgr, as WriteError does, so any logging library can be used. It
+// must also be deferred directly:
+//
+//	defer errs.RecoverWithLogger(w, errs.SlogLogger(logger))
+//
+// If lgr is nil, nothing is logged.
+func RecoverWithLogger(w http.ResponseWriter, lgr Logger) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	_, _ = WriteError(w, lgr, recovered(v, panicStack()))
+}
+
+// recovered returns the *Error for the panic value v, with the
+// given stack
+func recovered(v interface{}, stack []uintptr) *Error {
+	var err error
+	if ve, ok := v.(error); ok {
+		err = &wrapError{msg: "panic: " + ve.Error(), err: ve}
+	} else {
+		err = fmt.Errorf("panic: %v", v)
+	}
+	return &Error{
+		Kind:        Internal,
+		UserMessage: UserMessage(unanticipatedResponse().Message),
+		Err:         err,
+		stack:       stack,
+	}
+}
+
+// panicStack returns the program counters of the stack of the
+// panicking goroutine, starting at the panic site. It must be called
+// by the function deferred to recover, while the goroutine is
+// panicking, so the stack holds the frames of the runtime panic
+// handling, which are skipped.
+func panicStack() []uintptr {
+	pcs := callers(3)
+	for i, pc := range pcs {
+		if fn := runtime.FuncForPC(pc - 1); fn == nil || fn.Name() != "runtime.gopanic" {
+			continue
+		}
+		// skip the runtime frames raising the panic, e.g. for a
+		// nil pointer dereference
+		for i++; i < len(pcs); i++ {
+			if fn := runtime.FuncForPC(pcs[i] - 1); fn == nil || !strings.HasPrefix(fn.Name(), "runtime.") {
+				break
+			}
+		}
+		return pcs[i:]
+	}
+	return pcs
+}
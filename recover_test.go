@@ -0,0 +1,123 @@
+package errs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func panicking(v interface{}) {
+	panic(v)
+}
+
+func dereferencing() int {
+	var p *int
+	return *p
+}
+
+// recoverStack recovers from the panic of fn as RecoverHandler does,
+// returning the *Error built for it
+func recoverStack(fn func()) (e *Error) {
+	defer func() {
+		e = recovered(recover(), panicStack())
+	}()
+	fn()
+	return nil
+}
+
+func TestRecoverHandler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := httptest.NewRecorder()
+	func() {
+		defer RecoverHandler(w, zerolog.New(buf))
+		panicking("boom")
+	}()
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("RecoverHandler() status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	want := `{"error":{"kind":"internal_error","message":"Unexpected error - contact support"}}`
+	if w.Body.String() != want {
+		t.Errorf("RecoverHandler() body = %s, want %s", w.Body, want)
+	}
+	if !strings.Contains(buf.String(), "panic: boom") {
+		t.Errorf("RecoverHandler() log = %s, want the panic value", buf)
+	}
+
+	// no panic, no response
+	w = httptest.NewRecorder()
+	func() {
+		defer RecoverHandler(w, zerolog.Nop())
+	}()
+	if w.Body.Len() != 0 {
+		t.Errorf("RecoverHandler() body = %s, want none without a panic", w.Body)
+	}
+}
+
+func TestRecoverWithLogger(t *testing.T) {
+	lgr := &recordingLogger{}
+	w := httptest.NewRecorder()
+	func() {
+		defer RecoverWithLogger(w, lgr)
+		panicking("boom")
+	}()
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("RecoverWithLogger() status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if lgr.err == nil || !strings.Contains(lgr.err.Error(), "panic: boom") {
+		t.Errorf("RecoverWithLogger() logged %v, want the panic value", lgr.err)
+	}
+	var e *Error
+	if !errors.As(lgr.err, &e) || len(StackTrace(e)) == 0 || !strings.HasSuffix(StackTrace(e)[0].Function, "panicking") {
+		t.Errorf("RecoverWithLogger() logged the stack %v, want the stack of the panic", StackTrace(e))
+	}
+}
+
+func TestRecoverHandler_Abort(t *testing.T) {
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	defer RecoverHandler(httptest.NewRecorder(), zerolog.Nop())
+	panic(http.ErrAbortHandler)
+}
+
+func TestRecovered(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func()
+		wantFunc string
+		wantMsg  string
+	}{
+		{"value", func() { panicking("boom") }, "errs.panicking", "panic: boom"},
+		{"error", func() { panicking(io.ErrUnexpectedEOF) }, "errs.panicking", "panic: unexpected EOF"},
+		{"runtime error", func() { dereferencing() }, "errs.dereferencing", "panic: runtime error: invalid memory address or nil pointer dereference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := recoverStack(tt.fn)
+			if e.Kind != Internal {
+				t.Errorf("Kind = %v, want %v", e.Kind, Internal)
+			}
+			if got := e.Message(); got != tt.wantMsg {
+				t.Errorf("Message() = %q, want %q", got, tt.wantMsg)
+			}
+			frames := StackTrace(e)
+			if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, tt.wantFunc) {
+				t.Errorf("StackTrace()[0] = %v, want the panic site in %s", frames, tt.wantFunc)
+			}
+		})
+	}
+
+	if e := recoverStack(func() { panicking(io.ErrUnexpectedEOF) }); !errors.Is(e, io.ErrUnexpectedEOF) {
+		t.Errorf("errors.Is(recovered, io.ErrUnexpectedEOF) = false, want true")
+	}
+}
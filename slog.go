@@ -0,0 +1,59 @@
+//go:build go1.21
+// +build go1.21
+
+package errs
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// slogLogger adapts a *slog.Logger to the Logger interface
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// SlogLogger adapts a *slog.Logger from the standard library
+// to the Logger and LevelLogger interfaces.
+func SlogLogger(l *slog.Logger) Logger {
+	return slogLogger{logger: l}
+}
+
+// LogError logs err and fields as an slog.LevelError record. Fields
+// are added as attributes in key order so output is deterministic.
+func (l slogLogger) LogError(err error, msg string, fields map[string]interface{}) {
+	l.LogAt(ErrorLevel, err, msg, fields)
+}
+
+// LogAt logs err and fields as an slog record of the slog.Level
+// closest to level, as LogError does.
+func (l slogLogger) LogAt(level Level, err error, msg string, fields map[string]interface{}) {
+	attrs := make([]slog.Attr, 0, len(fields)+1)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+	l.logger.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+// slogLevel returns the slog.Level for a Level
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
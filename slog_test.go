@@ -0,0 +1,44 @@
+//go:build go1.21
+// +build go1.21
+
+package errs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	lgr := SlogLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+
+	w := httptest.NewRecorder()
+	err := E(Op("repo.Find"), NotExist, Code("user_not_found"), "no such user")
+	if _, werr := WriteError(w, lgr, err); werr != nil {
+		t.Fatalf("WriteError() error = %v", werr)
+	}
+	if w.Code != http.StatusNotFound {
+		t.Errorf("WriteError() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not JSON: %v", buf, err)
+	}
+	want := map[string]interface{}{
+		"level": "WARN",
+		"msg":   "Response Error Sent",
+		"error": err.Error(),
+		"Kind":  NotExist.String(),
+		"Code":  "user_not_found",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("log entry %q = %v, want %v", k, entry[k], v)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package errs
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
 
 // MissingField is an error type that can be used when
 // validating input fields that do not have a value, but should
@@ -18,6 +24,181 @@ func (e InputUnwanted) Error() string {
 	return string(e) + " has a value, but should be nil"
 }
 
+// NewValidation returns an input validation error for the
+// parameter param, i.e. an *Error with Kind Validation, Param
+// param and message as the error message.
+func NewValidation(param Parameter, message string) *Error {
+	return newValidation(param, message)
+}
+
+// Validationf is like NewValidation, but formats the error
+// message according to a format specifier.
+func Validationf(param Parameter, format string, args ...interface{}) *Error {
+	return newValidation(param, fmt.Sprintf(format, args...))
+}
+
+// InvalidQueryParam returns an input validation error for the query
+// string parameter param of r, like NewValidation. The error also
+// notes, in its Meta, that param came from the query string and the
+// value r had for it, if any, so both are logged but never sent to
+// the client:
+//
+//	if limit <= 0 {
+//		return errs.InvalidQueryParam(r, "limit", "limit must be positive")
+//	}
+func InvalidQueryParam(r *http.Request, param Parameter, message string) *Error {
+	return newRequestValidation(param, message, "query", r.URL.Query()[string(param)])
+}
+
+// InvalidFormParam is like InvalidQueryParam, for the parameter param
+// of the form sent in the body of r. The form must already have been
+// parsed, e.g. by r.ParseForm, for its value to be noted.
+func InvalidFormParam(r *http.Request, param Parameter, message string) *Error {
+	return newRequestValidation(param, message, "form", r.PostForm[string(param)])
+}
+
+// newRequestValidation builds the error for the request-aware
+// validation constructors, noting the source of the parameter and
+// its first value in values, if any
+func newRequestValidation(param Parameter, message, source string, values []string) *Error {
+	e := &Error{Kind: Validation, Param: param, Err: errors.New(message), stack: callers(4)}
+	e.Meta = Meta{"param_source": source}
+	if len(values) > 0 {
+		e.Meta["param_value"] = values[0]
+	}
+	return e
+}
+
+// newValidation builds the error for the exported validation
+// constructors, recording the stack of their caller
+func newValidation(param Parameter, message string) *Error {
+	return &Error{Kind: Validation, Param: param, Err: errors.New(message), stack: callers(4)}
+}
+
+// ValidationErrors collects several validation errors, usually
+// one per invalid parameter, so they can be reported together.
+// HTTPErrorResponse sends each of them in the Errors of a single
+// Validation ServiceError.
+type ValidationErrors []*Error
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, e := range v {
+		if e != nil {
+			msgs = append(msgs, stripStack(e))
+		}
+	}
+	if len(msgs) == 0 {
+		return "no validation errors"
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the validation errors, so errors.Is and errors.As
+// find each of them
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(v))
+	for _, e := range v {
+		if e != nil {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+func (v ValidationErrors) packageError() {}
+
+// ParamPath returns the Parameter for a nested or array field, with
+// its path segments from the outermost to the innermost, in the
+// format expected by most client-side form libraries: segments are
+// joined with a dot and a segment of digits only is an array index,
+// written in brackets, e.g.
+//
+//	ParamPath("items", "2", "price") // items[2].price
+//	ParamPath("addresses", "0")      // addresses[0]
+//	ParamPath("user", "email")       // user.email
+//
+// Empty segments are skipped.
+func ParamPath(segments ...string) Parameter {
+	var b strings.Builder
+	for _, seg := range segments {
+		switch {
+		case seg == "":
+			continue
+		case isIndex(seg):
+			b.WriteString("[" + seg + "]")
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(seg)
+		}
+	}
+	return Parameter(b.String())
+}
+
+// isIndex reports whether the path segment seg is an array index
+func isIndex(seg string) bool {
+	for _, r := range seg {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return seg != ""
+}
+
+// fieldError is the error of a single field validated by
+// github.com/go-playground/validator/v10, its FieldError, which is
+// matched by its methods so this package does not depend on it
+type fieldError interface {
+	error
+	Field() string
+	Tag() string
+	Param() string
+}
+
+// FromValidator converts the errors returned by the Struct method of
+// a github.com/go-playground/validator/v10 Validate, its
+// ValidationErrors, into ValidationErrors, with one Validation *Error
+// per invalid field, so HTTPErrorResponse sends all of them at once.
+// The Param of each *Error is the name of the field, as returned by
+// Field, and the message is derived from the failed tag, e.g.
+// "email is required" for the required tag or "age failed the
+// max=150 validation". Each *Error wraps its FieldError, so
+// errors.As still finds it.
+//
+// Any other error, e.g. the InvalidValidationError returned for a
+// value which is not a struct, is returned as an *Error of Kind
+// Validation wrapping it. FromValidator returns nil if err is nil.
+func FromValidator(err error) error {
+	if err == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(err)
+	if rv.Kind() != reflect.Slice {
+		return &Error{Kind: Validation, Err: err, stack: callers(3)}
+	}
+	stack := callers(3)
+	verrs := make(ValidationErrors, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		fe, ok := rv.Index(i).Interface().(fieldError)
+		if !ok {
+			return &Error{Kind: Validation, Err: err, stack: stack}
+		}
+		e := &Error{Kind: Validation, Param: Parameter(fe.Field()), stack: stack}
+		switch {
+		case fe.Tag() == "required":
+			e.Err = &wrapError{msg: MissingField(fe.Field()).Error(), err: fe}
+		case fe.Param() != "":
+			e.Err = &wrapError{msg: fmt.Sprintf("%s failed the %s=%s validation", fe.Field(), fe.Tag(), fe.Param()), err: fe}
+		default:
+			e.Err = &wrapError{msg: fmt.Sprintf("%s failed the %s validation", fe.Field(), fe.Tag()), err: fe}
+		}
+		verrs = append(verrs, e)
+	}
+	return verrs
+}
+
 // BazError is a temp error until I figure this out
 type BazError struct {
 	Reason string
@@ -1,9 +1,16 @@
 package errs
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/rs/zerolog"
 )
 
 func TestMissingField_Error(t *testing.T) {
@@ -52,6 +59,159 @@ func TestInputUnwanted_Error(t *testing.T) {
 	}
 }
 
+func TestInvalidQueryParam(t *testing.T) {
+	form := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("limit=-5"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.ParseForm(); err != nil {
+		t.Fatalf("ParseForm() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		err  *Error
+		want Meta
+	}{
+		{"query", InvalidQueryParam(httptest.NewRequest(http.MethodGet, "/items?limit=-5", nil), "limit", "limit must be positive"), Meta{"param_source": "query", "param_value": "-5"}},
+		{"query without value", InvalidQueryParam(httptest.NewRequest(http.MethodGet, "/items", nil), "limit", "limit must be positive"), Meta{"param_source": "query"}},
+		{"form", InvalidFormParam(form, "limit", "limit must be positive"), Meta{"param_source": "form", "param_value": "-5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Kind != Validation || tt.err.Param != "limit" || tt.err.Message() != "limit must be positive" {
+				t.Errorf("Kind, Param, Message = %v, %q, %q, want %v, %q, %q", tt.err.Kind, tt.err.Param, tt.err.Message(), Validation, "limit", "limit must be positive")
+			}
+			if !reflect.DeepEqual(tt.err.Meta, tt.want) {
+				t.Errorf("Meta = %v, want %v", tt.err.Meta, tt.want)
+			}
+			if frames := StackTrace(tt.err); len(frames) == 0 || !strings.Contains(frames[0].Function, "TestInvalidQueryParam") {
+				t.Errorf("StackTrace() does not start at the caller: %v", frames)
+			}
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			want := `{"error":{"kind":"input_validation_error","param":"limit","message":"limit must be positive"}}`
+			if w.Body.String() != want {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+			}
+		})
+	}
+}
+
+func TestValidationf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{"NewValidation", NewValidation("email", "email is invalid"), "email is invalid"},
+		{"Validationf", Validationf("email", "%q is not a valid email", "bob"), `"bob" is not a valid email`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Kind != Validation {
+				t.Errorf("Kind = %v, want %v", tt.err.Kind, Validation)
+			}
+			if tt.err.Param != "email" {
+				t.Errorf("Param = %q, want %q", tt.err.Param, "email")
+			}
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var er ErrResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil {
+				t.Fatalf("response body %q is not JSON: %v", w.Body, err)
+			}
+			want := ServiceError{Kind: Validation.String(), Param: "email", Message: tt.want}
+			if !reflect.DeepEqual(er.Error, want) {
+				t.Errorf("ServiceError = %+v, want %+v", er.Error, want)
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	v := ValidationErrors{
+		NewValidation("email", "email is invalid"),
+		E(Validation, Parameter("name"), Code("required"), MissingField("name")).(*Error),
+	}
+
+	wantMsg := "email is invalid; name is required"
+	if v.Error() != wantMsg {
+		t.Errorf("Error() = %q, want %q", v.Error(), wantMsg)
+	}
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), v)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	want := `{"error":{"kind":"input_validation_error","message":"email is invalid; name is required","errors":[` +
+		`{"kind":"input_validation_error","param":"email","message":"email is invalid"},` +
+		`{"kind":"input_validation_error","code":"required","param":"name","message":"name is required"}]}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+}
+
+func TestValidationErrors_Wrapped(t *testing.T) {
+	email := NewValidation("email", "email is invalid")
+	v := ValidationErrors{email, nil, NewValidation("name", "name is required")}
+	const wantBody = `{"error":{"kind":"input_validation_error","message":"email is invalid; name is required","errors":[` +
+		`{"kind":"input_validation_error","param":"email","message":"email is invalid"},` +
+		`{"kind":"input_validation_error","param":"name","message":"name is required"}]}}`
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"ValidationErrors", v},
+		{"fmt.Errorf", fmt.Errorf("parse: %w", v)},
+		{"Wrap", Wrap(v, "user.Create")},
+		{"Wrap of fmt.Errorf", Wrap(fmt.Errorf("parse: %w", v), "user.Create")},
+		{"E with Kind", E(Validation, Op("user.Create"), v)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != Validation {
+				t.Errorf("KindOf() = %v, want %v", got, Validation)
+			}
+			if !KindIs(Validation, tt.err) {
+				t.Error("KindIs(Validation) = false, want true")
+			}
+			if !errors.Is(tt.err, email) {
+				t.Error("errors.Is(err, email) = false, want true")
+			}
+			var got ValidationErrors
+			if !errors.As(tt.err, &got) || len(got) != len(v) {
+				t.Errorf("errors.As(err, ValidationErrors) = %v, want %v", got, v)
+			}
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, zerolog.Nop(), tt.err)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if w.Body.String() != wantBody {
+				t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, wantBody)
+			}
+		})
+	}
+
+	// ValidationErrors joined with another error keep their Kind
+	// and their errors
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), Join(v, E(NotExist, "no such team")))
+	const wantJoined = `{"error":{"kind":"item_does_not_exist","message":"email is invalid; name is required; no such team","errors":[` +
+		`{"kind":"input_validation_error","message":"email is invalid; name is required","errors":[` +
+		`{"kind":"input_validation_error","param":"email","message":"email is invalid"},` +
+		`{"kind":"input_validation_error","param":"name","message":"name is required"}]},` +
+		`{"kind":"item_does_not_exist","message":"no such team"}]}}`
+	if w.Body.String() != wantJoined {
+		t.Errorf("HTTPErrorResponse(Join()) body = %s, want %s", w.Body, wantJoined)
+	}
+}
+
 func TestBaz(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -104,3 +264,80 @@ func bazLayer1() error {
 	const op Op = "baz/bazLayer1"
 	return BazError{Reason: "Actual error message"}
 }
+
+// testFieldError mimics the FieldError of
+// github.com/go-playground/validator/v10
+type testFieldError struct {
+	field, tag, param string
+}
+
+func (e testFieldError) Error() string {
+	return "Key: 'User." + e.field + "' Error:Field validation for '" + e.field + "' failed on the '" + e.tag + "' tag"
+}
+
+func (e testFieldError) Field() string { return e.field }
+func (e testFieldError) Tag() string   { return e.tag }
+func (e testFieldError) Param() string { return e.param }
+
+// testValidatorErrors mimics the ValidationErrors of
+// github.com/go-playground/validator/v10, a slice of interfaces
+type testValidatorErrors []fieldError
+
+func (e testValidatorErrors) Error() string { return "validation failed" }
+
+func TestFromValidator(t *testing.T) {
+	err := FromValidator(testValidatorErrors{
+		testFieldError{field: "email", tag: "required"},
+		testFieldError{field: "age", tag: "max", param: "150"},
+		testFieldError{field: "website", tag: "url"},
+	})
+
+	w := httptest.NewRecorder()
+	HTTPErrorResponse(w, zerolog.Nop(), err)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("HTTPErrorResponse() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	want := `{"error":{"kind":"input_validation_error","message":"email is required; age failed the max=150 validation; website failed the url validation","errors":[` +
+		`{"kind":"input_validation_error","param":"email","message":"email is required"},` +
+		`{"kind":"input_validation_error","param":"age","message":"age failed the max=150 validation"},` +
+		`{"kind":"input_validation_error","param":"website","message":"website failed the url validation"}]}}`
+	if w.Body.String() != want {
+		t.Errorf("HTTPErrorResponse() body = %s, want %s", w.Body, want)
+	}
+
+	var fe testFieldError
+	if !errors.As(err.(ValidationErrors)[1], &fe) || fe.field != "age" {
+		t.Errorf("errors.As(FromValidator()[1], FieldError) = %+v, want the age FieldError", fe)
+	}
+
+	other := errors.New("validator: (nil *main.User)")
+	if e, ok := FromValidator(other).(*Error); !ok || e.Kind != Validation || !errors.Is(e, other) {
+		t.Errorf("FromValidator(%v) = %v, want a Validation *Error wrapping it", other, e)
+	}
+	if FromValidator(nil) != nil {
+		t.Errorf("FromValidator(nil) != nil")
+	}
+}
+
+func TestParamPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []string
+		want     Parameter
+	}{
+		{"nested array field", []string{"items", "2", "price"}, "items[2].price"},
+		{"array element", []string{"addresses", "0"}, "addresses[0]"},
+		{"nested arrays", []string{"matrix", "1", "3"}, "matrix[1][3]"},
+		{"nested field", []string{"user", "email"}, "user.email"},
+		{"single field", []string{"email"}, "email"},
+		{"empty segments", []string{"", "user", "", "email"}, "user.email"},
+		{"none", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParamPath(tt.segments...); got != tt.want {
+				t.Errorf("ParamPath(%q) = %q, want %q", tt.segments, got, tt.want)
+			}
+		})
+	}
+}